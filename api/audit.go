@@ -0,0 +1,263 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/swarm/cluster"
+	gorillacontext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// auditContainerKey is the key of the container created by a request in the
+// request variables.
+type auditContainerKey struct{}
+
+// auditTargetVars are the route variables naming the target of a request.
+var auditTargetVars = []string{"name", "networkid", "volumename", "execid", "tenant", "registry"}
+
+// AuditEntry records a request handled by the primary.
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Identity string    `json:"identity,omitempty"`
+	Remote   string    `json:"remote"`
+	Method   string    `json:"method"`
+	Route    string    `json:"route"`
+	URI      string    `json:"uri"`
+	// Target is the container, image, network, volume, exec, tenant or
+	// registry named in the route. Container and Engine are set when it is a
+	// container.
+	Target    string `json:"target,omitempty"`
+	Container string `json:"container,omitempty"`
+	Engine    string `json:"engine,omitempty"`
+	Status    int    `json:"status"`
+	// Duration is the latency of the request, in milliseconds.
+	Duration float64 `json:"duration"`
+}
+
+// Audit records the requests changing the cluster, one JSON object per line.
+// Request bodies, which can carry registry credentials, are never recorded.
+type Audit struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewAudit creates an audit writing its entries to w.
+func NewAudit(w io.Writer) *Audit {
+	return &Audit{w: w}
+}
+
+// OpenAudit creates an audit writing to syslog, when destination is "syslog"
+// or a syslog://host:port or syslog+tcp://host:port address, or else to a
+// file rotated when it grows over maxSize bytes, keeping maxBackups old files.
+func OpenAudit(destination string, maxSize int64, maxBackups int) (*Audit, error) {
+	if destination == "syslog" || strings.HasPrefix(destination, "syslog://") || strings.HasPrefix(destination, "syslog+tcp://") {
+		w, err := newSyslogWriter(destination)
+		if err != nil {
+			return nil, err
+		}
+		return NewAudit(w), nil
+	}
+	f, err := openRotatingFile(destination, maxSize, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	return NewAudit(f), nil
+}
+
+// begin starts the entry of a request, before it is handled so that the
+// engine of a removed container is still known.
+func (a *Audit) begin(c *context, r *http.Request, route string) *AuditEntry {
+	entry := &AuditEntry{
+		Time:   time.Now(),
+		Remote: remoteAddr(r),
+		Method: r.Method,
+		Route:  route,
+		URI:    r.URL.RequestURI(),
+	}
+	vars := mux.Vars(r)
+	for _, name := range auditTargetVars {
+		if target, ok := vars[name]; ok {
+			entry.Target = target
+			break
+		}
+	}
+	if entry.Target != "" && strings.HasPrefix(route, "/containers/") {
+		if container := c.cluster.Container(entry.Target); container != nil {
+			entry.setContainer(container)
+		}
+	}
+	return entry
+}
+
+// end completes and writes the entry of a request once handled.
+func (a *Audit) end(entry *AuditEntry, r *http.Request, w *auditResponseWriter) {
+	entry.Duration = float64(time.Since(entry.Time)) / float64(time.Millisecond)
+	entry.Identity = requestIdentity(r)
+	entry.Status = w.status
+	if entry.Status == 0 {
+		entry.Status = http.StatusOK
+	}
+	if container, ok := gorillacontext.Get(r, auditContainerKey{}).(*cluster.Container); ok {
+		entry.setContainer(container)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.WithError(err).Error("Unable to encode audit entry")
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.w.Write(append(data, '\n')); err != nil {
+		log.WithError(err).Error("Unable to write audit entry")
+	}
+}
+
+func (entry *AuditEntry) setContainer(container *cluster.Container) {
+	entry.Container = container.ID
+	if container.Engine != nil {
+		entry.Engine = container.Engine.Name
+	}
+}
+
+// auditContainer records the container created by a request in its audit
+// entry.
+func auditContainer(r *http.Request, container *cluster.Container) {
+	gorillacontext.Set(r, auditContainerKey{}, container)
+}
+
+// auditResponseWriter records the status of a response. It keeps the
+// hijacking, flushing and close notification of the wrapped writer.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *auditResponseWriter) CloseNotify() <-chan bool {
+	if closeNotifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return closeNotifier.CloseNotify()
+	}
+	return nil
+}
+
+func (w *auditResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("Docker server does not support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
+
+// rotatingFile is a file renamed to path.1, path.2... when it grows over
+// maxSize bytes.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			log.WithError(err).Error("Unable to rotate the audit log")
+			if f.file == nil {
+				return 0, err
+			}
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate moves the file to its first backup and opens a new one. When the
+// backups can't be moved, the entries keep being appended to the current
+// file, and the error is returned. file is nil if no file could be opened,
+// the next write tries again.
+func (f *rotatingFile) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+	err := f.shift()
+	if openErr := f.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+// shift renames the file and its backups to the next backup, dropping the
+// oldest one, or removes the file when there are no backups.
+func (f *rotatingFile) shift() error {
+	if f.maxBackups <= 0 {
+		return os.Remove(f.path)
+	}
+	for i := f.maxBackups - 1; i > 0; i-- {
+		// The backups are missing until the file has been rotated enough.
+		if err := os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(f.path, f.path+".1")
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/swarm/cluster"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudit(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	c := &context{
+		cluster: &containerCluster{containers: map[string]*cluster.Container{
+			"web": {Container: types.Container{ID: "web-id"}, Engine: &cluster.Engine{Name: "node-1"}},
+		}},
+		authorization: &Authorization{Rules: []AuthorizationRule{{Identities: []string{"admin"}, Allow: true}}},
+		audit:         NewAudit(buffer),
+	}
+	router := mux.NewRouter()
+	setupPrimaryRouter(router, c, false)
+
+	for _, method := range []string{"GET", "POST"} {
+		r := requestFrom("alice")
+		r.Method = method
+		r.URL.Path = "/v1.40/containers/web/kill"
+		if method == "GET" {
+			r.URL.Path = "/containers/json"
+		}
+		r.RequestURI = r.URL.RequestURI()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	}
+
+	// Only the POST is recorded.
+	lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 1)
+	entry := AuditEntry{}
+	assert.NoError(t, json.Unmarshal(lines[0], &entry))
+	assert.Equal(t, "alice", entry.Identity)
+	assert.Equal(t, "POST", entry.Method)
+	assert.Equal(t, "/containers/{name:.*}/kill", entry.Route)
+	assert.Equal(t, "/v1.40/containers/web/kill", entry.URI)
+	assert.Equal(t, "web", entry.Target)
+	assert.Equal(t, "web-id", entry.Container)
+	assert.Equal(t, "node-1", entry.Engine)
+	assert.Equal(t, http.StatusForbidden, entry.Status)
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	f, err := openRotatingFile(path, 10, 2)
+	assert.NoError(t, err)
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := f.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+
+	for name, content := range map[string]string{"audit.log": "fourth\n", "audit.log.1": "third\n", "audit.log.2": "second\n"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	// When the backups can't be moved, the entries are kept in the file.
+	assert.NoError(t, os.Remove(path+".2"))
+	assert.NoError(t, os.MkdirAll(filepath.Join(path+".2", "busy"), 0700))
+	assert.Error(t, f.rotate())
+	_, err = f.Write([]byte("fifth\n"))
+	assert.NoError(t, err)
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "fourth\nfifth\n", string(data))
+}
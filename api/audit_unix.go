@@ -0,0 +1,23 @@
+// +build !windows
+
+package api
+
+import (
+	"io"
+	"log/syslog"
+	"strings"
+)
+
+// newSyslogWriter connects to the local syslog, or to the remote syslog of a
+// syslog://host:port (UDP) or syslog+tcp://host:port address.
+func newSyslogWriter(destination string) (io.Writer, error) {
+	const priority = syslog.LOG_INFO | syslog.LOG_AUTH
+	if destination == "syslog" {
+		return syslog.New(priority, "swarm-audit")
+	}
+	network := "udp"
+	if strings.HasPrefix(destination, "syslog+tcp://") {
+		network = "tcp"
+	}
+	return syslog.Dial(network, destination[strings.Index(destination, "://")+3:], priority, "swarm-audit")
+}
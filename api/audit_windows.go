@@ -0,0 +1,12 @@
+// +build windows
+
+package api
+
+import (
+	"fmt"
+	"io"
+)
+
+func newSyslogWriter(destination string) (io.Writer, error) {
+	return nil, fmt.Errorf("Windows platform does not support syslog")
+}
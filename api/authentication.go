@@ -0,0 +1,188 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	// the request variables of mux are kept by gorilla/context, and there is
+	// already a type called `context` declared in primary.go
+	gorillacontext "github.com/gorilla/context"
+)
+
+// errNoCredential is returned for the requests without credential nor
+// verified TLS client certificate.
+var errNoCredential = errors.New("a verified TLS client certificate or an API key is required")
+
+// identityKey is the key of the identity authenticated from the
+// Authorization header in the request variables.
+type identityKey struct{}
+
+// Authentication authenticates the clients sending an API key or a signed
+// token in the Authorization header, as an alternative to TLS client
+// certificates.
+type Authentication struct {
+	// Keys maps identities to their static API key.
+	Keys map[string]string
+	// Secret is the HMAC secret signing the JSON web tokens (HS256). The
+	// identity of a token is its "sub" claim, and its "exp" claim is
+	// required.
+	Secret string
+	// Issuer is the "iss" claim required in the tokens, if set.
+	Issuer string
+}
+
+// LoadAuthentication reads the API keys and the token secret from a JSON file.
+func LoadAuthentication(path string) (*Authentication, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	authentication := &Authentication{}
+	if err := json.Unmarshal(data, authentication); err != nil {
+		return nil, fmt.Errorf("invalid authentication: %v", err)
+	}
+	if len(authentication.Keys) == 0 && authentication.Secret == "" {
+		return nil, errors.New("invalid authentication: no keys and no token secret")
+	}
+	for identity, key := range authentication.Keys {
+		if identity == "" || key == "" {
+			return nil, errors.New("invalid authentication: empty identity or key")
+		}
+	}
+	return authentication, nil
+}
+
+// authenticate records the identity of the Authorization header of r. A
+// request without Authorization header is left to be identified by its
+// verified TLS certificate, or by the one forwarded by a replica, and is
+// refused if it has none.
+func (a *Authentication) authenticate(r *http.Request) error {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		if client := forwarded(r); client != nil {
+			if len(client.identities) == 0 {
+				return errNoCredential
+			}
+			return nil
+		}
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			return errNoCredential
+		}
+		return nil
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return errors.New("unsupported authorization scheme, expected a Bearer token")
+	}
+	credential := strings.TrimSpace(parts[1])
+
+	identity := a.keyIdentity(credential)
+	if identity == "" {
+		var err error
+		if identity, err = a.tokenIdentity(credential, time.Now()); err != nil {
+			return err
+		}
+	}
+	gorillacontext.Set(r, identityKey{}, identity)
+	return nil
+}
+
+// keyIdentity returns the identity of an API key, or an empty string.
+func (a *Authentication) keyIdentity(key string) string {
+	identity := ""
+	for id, k := range a.Keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			identity = id
+		}
+	}
+	return identity
+}
+
+// tokenClaims are the claims of a token checked by the manager.
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	Issuer    string `json:"iss"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+}
+
+// tokenIdentity verifies a JSON web token signed with HS256 and returns its
+// subject.
+func (a *Authentication) tokenIdentity(token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if a.Secret == "" || len(parts) != 3 {
+		return "", errors.New("invalid API key")
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	if err := decodeTokenPart(parts[0], &header); err != nil {
+		return "", err
+	}
+	if header.Algorithm != "HS256" {
+		return "", fmt.Errorf("invalid token: unsupported algorithm %q", header.Algorithm)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("invalid token: malformed signature")
+	}
+	mac := hmac.New(sha256.New, []byte(a.Secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return "", errors.New("invalid token: bad signature")
+	}
+
+	claims := tokenClaims{}
+	if err := decodeTokenPart(parts[1], &claims); err != nil {
+		return "", err
+	}
+	switch {
+	case claims.Subject == "":
+		return "", errors.New("invalid token: no subject")
+	case a.Issuer != "" && claims.Issuer != a.Issuer:
+		return "", fmt.Errorf("invalid token: unexpected issuer %q", claims.Issuer)
+	case claims.ExpiresAt == 0:
+		return "", errors.New("invalid token: no expiration time")
+	case now.Unix() >= claims.ExpiresAt:
+		return "", errors.New("invalid token: expired")
+	case claims.NotBefore != 0 && now.Unix() < claims.NotBefore:
+		return "", errors.New("invalid token: not valid yet")
+	}
+	return claims.Subject, nil
+}
+
+func decodeTokenPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("invalid token: malformed encoding")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid token: %v", err)
+	}
+	return nil
+}
+
+// authenticatedIdentity returns the identity authenticated from the
+// Authorization header of r, or an empty string.
+func authenticatedIdentity(r *http.Request) string {
+	identity, _ := gorillacontext.Get(r, identityKey{}).(string)
+	return identity
+}
+
+// unauthorized sends a Docker style error with a 401 status.
+func unauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="swarm"`)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+}
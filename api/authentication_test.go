@@ -0,0 +1,129 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func signToken(secret, claims string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(header + "." + payload))
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestTokenIdentity(t *testing.T) {
+	a := &Authentication{Secret: "s3cret", Issuer: "ci"}
+	now := time.Unix(1000, 0)
+
+	identity, err := a.tokenIdentity(signToken("s3cret", `{"sub":"deployer","iss":"ci","exp":2000}`), now)
+	assert.NoError(t, err)
+	assert.Equal(t, "deployer", identity)
+
+	for _, token := range []string{
+		signToken("s3cret", `{"sub":"deployer","iss":"ci","exp":1000}`),
+		signToken("s3cret", `{"sub":"deployer","iss":"ci","exp":2000,"nbf":1500}`),
+		signToken("s3cret", `{"sub":"deployer","iss":"other","exp":2000}`),
+		signToken("s3cret", `{"iss":"ci","exp":2000}`),
+		signToken("other", `{"sub":"deployer","iss":"ci","exp":2000}`),
+		// Tokens must expire.
+		signToken("s3cret", `{"sub":"deployer","iss":"ci"}`),
+		"eyJhbGciOiJub25lIn0.eyJzdWIiOiJkZXBsb3llciJ9.",
+		"not-a-token",
+	} {
+		_, err := a.tokenIdentity(token, now)
+		assert.Error(t, err, token)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-authentication")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "authentication.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"Keys": {"reader": "r3ad"}, "Secret": "s3cret"}`), 0644))
+
+	authentication, err := LoadAuthentication(path)
+	assert.NoError(t, err)
+	authorization := &Authorization{Rules: []AuthorizationRule{
+		{Identities: []string{"deployer"}, Allow: true},
+		{Identities: []string{"reader"}, Methods: []string{"GET"}, Allow: true},
+	}}
+
+	router := mux.NewRouter()
+	setupPrimaryRouter(router, &context{authentication: authentication, authorization: authorization}, false)
+
+	request := func(method, path, credential string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+		if credential != "" {
+			r.Header.Set("Authorization", credential)
+		}
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, request("GET", "/_ping", "Bearer r3ad"))
+	assert.Equal(t, http.StatusForbidden, request("POST", "/containers/create", "Bearer r3ad"))
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/_ping", "Bearer wrong"))
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/_ping", "Basic cmVhZGVyOnIzYWQ="))
+	assert.Equal(t, http.StatusOK, request("GET", "/_ping", "Bearer "+signToken("s3cret", fmt.Sprintf(`{"sub":"deployer","exp":%d}`, time.Now().Add(time.Hour).Unix()))))
+	// The credentials aren't passed on to the engines.
+	r := httptest.NewRequest("GET", "/_ping", nil)
+	r.Header.Set("Authorization", "Bearer r3ad")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Empty(t, r.Header.Get("Authorization"))
+	// Without credentials, the client has to be identified by a verified
+	// certificate.
+	assert.Equal(t, http.StatusUnauthorized, request("GET", "/_ping", ""))
+	for verified, code := range map[bool]int{true: http.StatusOK, false: http.StatusUnauthorized} {
+		w := httptest.NewRecorder()
+		r := requestFrom("reader")
+		if verified {
+			r.TLS.VerifiedChains = [][]*x509.Certificate{r.TLS.PeerCertificates}
+		}
+		router.ServeHTTP(w, r)
+		assert.Equal(t, code, w.Code)
+	}
+
+	for _, content := range []string{`{}`, `{"Keys": {"reader": ""}}`, `{"Keys": `} {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+		_, err := LoadAuthentication(path)
+		assert.Error(t, err, content)
+	}
+}
+
+func TestReplicaForwardsAuthorization(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+	}))
+	defer primary.Close()
+
+	replica := NewReplica(http.NotFoundHandler(), nil, "replica")
+	replica.SetPrimary(primary.URL)
+	server := httptest.NewServer(replica)
+	defer server.Close()
+
+	r, err := http.NewRequest("GET", server.URL+"/containers/json", nil)
+	assert.NoError(t, err)
+	r.Header.Set("Authorization", "Bearer r3ad")
+	r.Close = true
+	resp, err := http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "Bearer r3ad", (<-headers).Get("Authorization"))
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// anyIdentity matches every identified client in authorization rules.
+const anyIdentity = "*"
+
+// AuthorizationRule allows or denies the requests of some identities on some
+// routes. Empty Methods or Routes match every method or route. Routes are the
+// route templates of the API, such as /containers/{name:.*}/json.
+type AuthorizationRule struct {
+	Identities []string
+	Methods    []string
+	Routes     []string
+	Allow      bool
+}
+
+// Authorization holds the rules authorizing API requests. The first rule
+// matching a request decides, requests matching no rule are denied.
+type Authorization struct {
+	Rules []AuthorizationRule
+}
+
+// LoadAuthorization reads authorization rules from a JSON file.
+func LoadAuthorization(path string) (*Authorization, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	authorization := &Authorization{}
+	if err := json.Unmarshal(data, authorization); err != nil {
+		return nil, fmt.Errorf("invalid authorization rules: %v", err)
+	}
+	for i, rule := range authorization.Rules {
+		if len(rule.Identities) == 0 {
+			return nil, fmt.Errorf("invalid authorization rules: rule #%d has no identities", i+1)
+		}
+		for _, route := range rule.Routes {
+			if !isRoute(route) {
+				return nil, fmt.Errorf("invalid authorization rules: unknown route %s in rule #%d", route, i+1)
+			}
+		}
+	}
+	return authorization, nil
+}
+
+// isRoute returns true if route is a route of the API.
+func isRoute(route string) bool {
+	for _, mappings := range routes {
+		if _, ok := mappings[route]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize returns an error if the client sending r isn't allowed to use
+// route with method.
+func (a *Authorization) authorize(r *http.Request, method, route string) error {
+	identities := requestIdentities(r)
+	if len(identities) == 0 {
+		return fmt.Errorf("unable to identify the client, a TLS client certificate or an API key is required")
+	}
+	for _, rule := range a.Rules {
+		if rule.matches(identities, method, route) {
+			if rule.Allow {
+				return nil
+			}
+			break
+		}
+	}
+	return fmt.Errorf("%s is not authorized to use %s %s", identities[0], method, route)
+}
+
+func (rule *AuthorizationRule) matches(identities []string, method, route string) bool {
+	return matchesAny(rule.Identities, identities...) &&
+		(len(rule.Methods) == 0 || matchesAny(rule.Methods, method)) &&
+		(len(rule.Routes) == 0 || matchesAny(rule.Routes, route))
+}
+
+// matchesAny returns true if one of the values is in the patterns, or if the
+// patterns contain the wildcard.
+func matchesAny(patterns []string, values ...string) bool {
+	for _, pattern := range patterns {
+		if pattern == anyIdentity {
+			return true
+		}
+		for _, value := range values {
+			if strings.EqualFold(pattern, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requestIdentities returns the identities of the client sending r: the
+// identity of its API key or token, or else the common name and the subject
+// alternative names of its TLS certificate, as forwarded by the replica which
+// received r if any.
+func requestIdentities(r *http.Request) []string {
+	if identity := authenticatedIdentity(r); identity != "" {
+		return []string{identity}
+	}
+	if client := forwarded(r); client != nil {
+		return client.identities
+	}
+	return certIdentities(r)
+}
+
+// certIdentities returns the common name and the subject alternative names
+// of the TLS certificate of the client sending r.
+func certIdentities(r *http.Request) []string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := r.TLS.PeerCertificates[0]
+	identities := []string{}
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	identities = append(identities, cert.DNSNames...)
+	identities = append(identities, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		identities = append(identities, ip.String())
+	}
+	return identities
+}
+
+// forbidden sends a Docker style error with a 403 status.
+func forbidden(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+}
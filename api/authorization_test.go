@@ -0,0 +1,98 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAuthorization = `{
+	"Rules": [
+		{"Identities": ["operator"], "Methods": ["DELETE"], "Routes": ["/images/{name:.*}"], "Allow": false},
+		{"Identities": ["operator", "admin.example.com"], "Allow": true},
+		{"Identities": ["*"], "Methods": ["GET", "HEAD"], "Allow": true}
+	]
+}`
+
+func TestAuthorization(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-authorization")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "authorization.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(testAuthorization), 0644))
+
+	authorization, err := LoadAuthorization(path)
+	assert.NoError(t, err)
+
+	request := func(cert *x509.Certificate) *http.Request {
+		r := httptest.NewRequest("GET", "/_ping", nil)
+		if cert != nil {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		}
+		return r
+	}
+	var (
+		reader   = request(&x509.Certificate{Subject: pkix.Name{CommonName: "reader"}})
+		operator = request(&x509.Certificate{Subject: pkix.Name{CommonName: "operator"}})
+		admin    = request(&x509.Certificate{Subject: pkix.Name{CommonName: "someone"}, DNSNames: []string{"admin.example.com"}})
+	)
+
+	assert.NoError(t, authorization.authorize(reader, "GET", "/containers/json"))
+	assert.Error(t, authorization.authorize(reader, "POST", "/containers/create"))
+	assert.NoError(t, authorization.authorize(operator, "POST", "/containers/create"))
+	assert.NoError(t, authorization.authorize(operator, "DELETE", "/containers/{name:.*}"))
+	assert.Error(t, authorization.authorize(operator, "DELETE", "/images/{name:.*}"))
+	assert.NoError(t, authorization.authorize(admin, "DELETE", "/images/{name:.*}"))
+	assert.Error(t, authorization.authorize(request(nil), "GET", "/_ping"))
+
+	// Denied requests get a Docker style error.
+	router := mux.NewRouter()
+	setupPrimaryRouter(router, &context{authorization: authorization}, false)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/v1.40/containers/create", nil)
+	r.TLS = reader.TLS
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	message := map[string]string{}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&message))
+	assert.Contains(t, message["message"], "reader is not authorized to use POST /containers/create")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, reader)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Behind a replica, the rules apply to the identities of the client
+	// forwarded by the replica, not to the replica itself.
+	forwardedBy := func(manager *x509.Certificate, identities ...string) *http.Request {
+		r := request(manager)
+		r.TLS.VerifiedChains = [][]*x509.Certificate{{manager}}
+		r.Header[forwardedIdentityHeader] = identities
+		r.Header.Set(forwardedForHeader, "10.0.0.1")
+		(&context{managerIdentity: "manager"}).trustForwarded(r)
+		return r
+	}
+	manager := &x509.Certificate{Subject: pkix.Name{CommonName: "manager"}, DNSNames: []string{"admin.example.com"}}
+	assert.Error(t, authorization.authorize(forwardedBy(manager, "reader"), "POST", "/containers/create"))
+	assert.NoError(t, authorization.authorize(forwardedBy(manager, "someone", "admin.example.com"), "DELETE", "/images/{name:.*}"))
+	assert.Error(t, authorization.authorize(forwardedBy(manager), "GET", "/_ping"))
+
+	for _, rules := range []string{
+		`{"Rules": [{"Allow": true}]}`,
+		`{"Rules": [{"Identities": ["*"], "Routes": ["/unknown"]}]}`,
+		`{"Rules": [`,
+	} {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(rules), 0644))
+		_, err := LoadAuthorization(path)
+		assert.Error(t, err, rules)
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+
+	gorillacontext "github.com/gorilla/context"
+)
+
+// The headers in which a replica forwards the certificate identities and the
+// address of its clients to the primary. The primary only trusts them from
+// the other managers. The common name is forwarded apart from the other
+// identities, as it alone identifies the client in namespaces.
+const (
+	forwardedCommonNameHeader = "X-Swarm-Forwarded-Common-Name"
+	forwardedIdentityHeader   = "X-Swarm-Forwarded-Identity"
+	forwardedForHeader        = "X-Swarm-Forwarded-For"
+)
+
+// forwardedKey is the key of the client forwarded by a replica in the
+// request variables.
+type forwardedKey struct{}
+
+// forwardedClient is the client of a request forwarded by a replica.
+type forwardedClient struct {
+	// commonName is the common name of the TLS certificate of the client.
+	commonName string
+	// identities are the identities of the TLS certificate of the client.
+	identities []string
+	// addr is the IP address of the client.
+	addr string
+}
+
+// forwardClient records the certificate identities and the address of the
+// client sending r in headers, before a replica forwards r to the primary.
+// The headers sent by the client itself are dropped.
+func forwardClient(r *http.Request) {
+	r.Header.Del(forwardedCommonNameHeader)
+	r.Header.Del(forwardedIdentityHeader)
+	r.Header.Del(forwardedForHeader)
+	if commonName := certCommonName(r); commonName != "" {
+		r.Header.Set(forwardedCommonNameHeader, commonName)
+	}
+	for _, identity := range certIdentities(r) {
+		r.Header.Add(forwardedIdentityHeader, identity)
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		r.Header.Set(forwardedForHeader, host)
+	}
+}
+
+// managerIdentity returns the common name of the certificate of the manager,
+// which the replicas present when they forward requests to the primary.
+func managerIdentity(tlsConfig *tls.Config) string {
+	if tlsConfig == nil || len(tlsConfig.Certificates) == 0 || len(tlsConfig.Certificates[0].Certificate) == 0 {
+		return ""
+	}
+	cert, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		return ""
+	}
+	return cert.Subject.CommonName
+}
+
+// trustForwarded records the client forwarded in the headers of r when r is
+// sent by another manager, that is by a peer with a verified certificate
+// having the common name of the certificate of this manager. The forwarding
+// headers are removed in any case.
+func (c *context) trustForwarded(r *http.Request) {
+	commonName := r.Header.Get(forwardedCommonNameHeader)
+	identities := r.Header[forwardedIdentityHeader]
+	addr := r.Header.Get(forwardedForHeader)
+	r.Header.Del(forwardedCommonNameHeader)
+	r.Header.Del(forwardedIdentityHeader)
+	r.Header.Del(forwardedForHeader)
+	if c.managerIdentity == "" || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return
+	}
+	if r.TLS.PeerCertificates[0].Subject.CommonName != c.managerIdentity {
+		return
+	}
+	if len(identities) == 0 && addr == "" {
+		return
+	}
+	gorillacontext.Set(r, forwardedKey{}, &forwardedClient{commonName: commonName, identities: identities, addr: addr})
+}
+
+// certCommonName returns the common name of the TLS certificate of the client
+// sending r, or an empty string.
+func certCommonName(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return r.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+// forwarded returns the client of r if r was forwarded by a replica, or nil.
+func forwarded(r *http.Request) *forwardedClient {
+	client, _ := gorillacontext.Get(r, forwardedKey{}).(*forwardedClient)
+	return client
+}
+
+// remoteAddr returns the address of the client sending r, as forwarded by the
+// replica which received r if any.
+func remoteAddr(r *http.Request) string {
+	if client := forwarded(r); client != nil && client.addr != "" {
+		return client.addr
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwarding(t *testing.T) {
+	// A replica forwards the identity and the address of its client, and
+	// drops the headers sent by the client.
+	r := requestFrom("alice")
+	r.RemoteAddr = "10.0.0.1:4242"
+	r.Header.Set(forwardedCommonNameHeader, "admin")
+	r.Header.Set(forwardedIdentityHeader, "admin")
+	r.Header.Set(forwardedForHeader, "10.0.0.2")
+	forwardClient(r)
+	assert.Equal(t, "alice", r.Header.Get(forwardedCommonNameHeader))
+	assert.Equal(t, []string{"alice"}, r.Header[forwardedIdentityHeader])
+	assert.Equal(t, "10.0.0.1", r.Header.Get(forwardedForHeader))
+
+	fromPeer := func(identity string, verified bool) *http.Request {
+		r := requestFrom(identity)
+		if verified {
+			r.TLS.VerifiedChains = [][]*x509.Certificate{r.TLS.PeerCertificates}
+		}
+		r.Header.Set(forwardedCommonNameHeader, "alice")
+		r.Header.Set(forwardedIdentityHeader, "alice")
+		r.Header.Set(forwardedForHeader, "10.0.0.1")
+		return r
+	}
+	c := &context{managerIdentity: "manager"}
+
+	// The primary trusts the headers sent by another manager.
+	r = fromPeer("manager", true)
+	c.trustForwarded(r)
+	assert.Equal(t, "alice", requestIdentity(r))
+	assert.Empty(t, r.Header.Get(forwardedCommonNameHeader))
+	assert.Empty(t, r.Header.Get(forwardedIdentityHeader))
+
+	// And ignores the headers sent by the other clients.
+	for _, r := range []*http.Request{fromPeer("bob", true), fromPeer("manager", false)} {
+		c.trustForwarded(r)
+		assert.Nil(t, forwarded(r))
+		assert.Empty(t, r.Header.Get(forwardedIdentityHeader))
+	}
+
+	// A client without certificate behind a replica has no identity.
+	r = fromPeer("manager", true)
+	r.Header.Del(forwardedCommonNameHeader)
+	r.Header.Del(forwardedIdentityHeader)
+	c.trustForwarded(r)
+	assert.Equal(t, "", requestIdentity(r))
+
+	// As without replica, only the common name identifies the client, not
+	// its subject alternative names.
+	r = requestFrom("")
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{DNSNames: []string{"admin"}}}}
+	assert.Equal(t, "", requestIdentity(r))
+	forwardClient(r)
+	assert.Empty(t, r.Header.Get(forwardedCommonNameHeader))
+	forwardedRequest := fromPeer("manager", true)
+	forwardedRequest.Header = r.Header
+	c.trustForwarded(forwardedRequest)
+	assert.Equal(t, []string{"admin"}, forwarded(forwardedRequest).identities)
+	assert.Equal(t, "", requestIdentity(forwardedRequest))
+	assert.Equal(t, "192.0.2.1", requestClient(forwardedRequest))
+}
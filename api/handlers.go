@@ -309,6 +309,9 @@ func getNetworks(c *context, w http.ResponseWriter, r *http.Request) {
 	out := []*apitypes.NetworkResource{}
 	networks := c.cluster.Networks().Filter(filters)
 	for _, network := range networks {
+		if !c.canSee(network.Labels) {
+			continue
+		}
 		tmp := (*network).NetworkResource
 		if tmp.Scope == "local" {
 			tmp.Name = network.Engine.Name + "/" + network.Name
@@ -322,7 +325,7 @@ func getNetworks(c *context, w http.ResponseWriter, r *http.Request) {
 // GET /networks/{networkid:.*}
 func getNetwork(c *context, w http.ResponseWriter, r *http.Request) {
 	var id = mux.Vars(r)["networkid"]
-	if network := c.cluster.Networks().Uniq().Get(id); network != nil {
+	if network := c.network(id); network != nil {
 		// there could be duplicate container endpoints in network, need to remove redundant
 		// see https://github.com/docker/swarm/issues/1969
 		cleanNetwork := network.RemoveDuplicateEndpoints()
@@ -336,7 +339,7 @@ func getNetwork(c *context, w http.ResponseWriter, r *http.Request) {
 // GET /volumes/{volumename:.*}
 func getVolume(c *context, w http.ResponseWriter, r *http.Request) {
 	var name = mux.Vars(r)["volumename"]
-	if volume := c.cluster.Volumes().Get(name); volume != nil {
+	if volume := c.volume(name); volume != nil {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(volume.Volume)
 		return
@@ -358,6 +361,10 @@ func getVolumes(c *context, w http.ResponseWriter, r *http.Request) {
 	names := filters.Get("name")
 	nodes := filters.Get("node")
 	for _, volume := range c.cluster.Volumes() {
+		if !c.canSee(volume.Labels) {
+			continue
+		}
+
 		// Check if the volume matches any name filters
 		found := false
 		for _, name := range names {
@@ -416,7 +423,7 @@ func getContainersJSON(c *context, w http.ResponseWriter, r *http.Request) {
 		before *cluster.Container
 	)
 	if value := r.FormValue("before"); value != "" {
-		before = c.cluster.Container(value)
+		before = c.container(value)
 		if before == nil {
 			httpError(w, fmt.Sprintf("No such container %s", value), http.StatusNotFound)
 			return
@@ -466,9 +473,15 @@ func getContainersJSON(c *context, w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Filtering: select the containers we want to return.
+	// Filtering: select the containers we want to return. Label filters and
+	// exact name filters are resolved through the cluster index first, so
+	// that only the matching containers are scanned below.
+	labels := filters.Get("label")
+	if c.namespace != "" {
+		labels = append(labels, namespaceLabel+"="+c.namespace)
+	}
 	candidates := []*cluster.Container{}
-	for _, container := range c.cluster.Containers() {
+	for _, container := range c.cluster.LookupContainers(exactNameFilters(filters), labels) {
 		// Skip stopped containers unless -a was specified
 		if (!container.Info.State.Running || !container.Engine.IsHealthy()) && !all && before == nil && limit <= 0 {
 			continue
@@ -617,7 +630,7 @@ func getContainersJSON(c *context, w http.ResponseWriter, r *http.Request) {
 // GET /containers/{name:.*}/json
 func getContainerJSON(c *context, w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
-	container := c.cluster.Container(name)
+	container := c.container(name)
 	if container == nil {
 		httpError(w, fmt.Sprintf("No such container %s", name), http.StatusNotFound)
 		return
@@ -686,6 +699,7 @@ func postContainersCreate(c *context, w http.ResponseWriter, r *http.Request) {
 	// make sure HostConfig fields are consolidated before creating container
 	cluster.ConsolidateResourceFields(&oldconfig)
 	config = oldconfig.ContainerConfig
+	config.Labels = c.setNamespace(config.Labels)
 
 	// Pass auth information along if present
 	var authConfig *apitypes.AuthConfig
@@ -699,11 +713,17 @@ func postContainersCreate(c *context, w http.ResponseWriter, r *http.Request) {
 		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if err := c.checkReferences(containerConfig); err != nil {
+		httpError(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	containerConfig.NetworkingConfig = stripNodeNamesFromNetworkingConfig(containerConfig.NetworkingConfig, c.cluster.EngineNames())
 
 	container, err := c.cluster.CreateContainer(containerConfig, name, authConfig)
 	if err != nil {
-		if strings.HasPrefix(err.Error(), "Conflict") {
+		if isForbidden(err) {
+			httpError(w, err.Error(), http.StatusForbidden)
+		} else if strings.HasPrefix(err.Error(), "Conflict") {
 			httpError(w, err.Error(), http.StatusConflict)
 		} else {
 			httpError(w, err.Error(), http.StatusInternalServerError)
@@ -711,6 +731,7 @@ func postContainersCreate(c *context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	auditContainer(r, container)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	fmt.Fprintf(w, "{%q:%q}", "Id", container.ID)
@@ -727,7 +748,7 @@ func deleteContainers(c *context, w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
 	force := boolValue(r, "force")
 	volumes := boolValue(r, "v")
-	container := c.cluster.Container(name)
+	container := c.container(name)
 	if container == nil {
 		httpError(w, fmt.Sprintf("Container %s not found", name), http.StatusNotFound)
 		return
@@ -751,6 +772,7 @@ func postNetworksCreate(c *context, w http.ResponseWriter, r *http.Request) {
 	if request.Driver == "" {
 		request.Driver = "overlay"
 	}
+	request.Labels = c.setNamespace(request.Labels)
 
 	response, err := c.cluster.CreateNetwork(request.Name, &request.NetworkCreate)
 	if err != nil {
@@ -772,6 +794,7 @@ func postVolumesCreate(c *context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	request.Labels = c.setNamespace(request.Labels)
 	volume, err := c.cluster.CreateVolume(&request)
 	if err != nil {
 		httpError(w, err.Error(), http.StatusInternalServerError)
@@ -945,7 +968,7 @@ func getEvents(c *context, w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			e, ok := eChan.(*cluster.Event)
-			if !ok {
+			if !ok || !c.ownsEvent(e) {
 				break
 			}
 			data, err := normalizeEvent(e)
@@ -971,7 +994,7 @@ func getEvents(c *context, w http.ResponseWriter, r *http.Request) {
 // POST /containers/{name:.*}/start
 func postContainersStart(c *context, w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
-	container := c.cluster.Container(name)
+	container := c.container(name)
 	if container == nil {
 		httpError(w, fmt.Sprintf("No such container %s", name), http.StatusNotFound)
 		return
@@ -1002,7 +1025,7 @@ func postExecStart(c *context, w http.ResponseWriter, r *http.Request) {
 // POST /containers/{name:.*}/exec
 func postContainersExec(c *context, w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
-	container := c.cluster.Container(name)
+	container := c.container(name)
 	if container == nil {
 		httpError(w, fmt.Sprintf("No such container %s", name), http.StatusNotFound)
 		return
@@ -1065,7 +1088,7 @@ func deleteNetworks(c *context, w http.ResponseWriter, r *http.Request) {
 
 	var id = mux.Vars(r)["networkid"]
 
-	if network := c.cluster.Networks().Uniq().Get(id); network != nil {
+	if network := c.network(id); network != nil {
 		if err := c.cluster.RemoveNetwork(network); err != nil {
 			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -1085,6 +1108,11 @@ func deleteVolumes(c *context, w http.ResponseWriter, r *http.Request) {
 	}
 	var name = mux.Vars(r)["name"]
 
+	if c.cluster.Volumes().Get(name) != nil && c.volume(name) == nil {
+		httpError(w, fmt.Sprintf("No such volume %s", name), http.StatusNotFound)
+		return
+	}
+
 	found, err := c.cluster.RemoveVolumes(name)
 	if err != nil {
 		httpError(w, err.Error(), http.StatusInternalServerError)
@@ -1106,7 +1134,7 @@ func ping(c *context, w http.ResponseWriter, r *http.Request) {
 // POST /networks/{networkid:.*}/disconnect
 func networkDisconnect(c *context, w http.ResponseWriter, r *http.Request) {
 	var networkid = mux.Vars(r)["networkid"]
-	network := c.cluster.Networks().Uniq().Get(networkid)
+	network := c.network(networkid)
 	if network == nil {
 		httpError(w, fmt.Sprintf("No such network: %s", networkid), http.StatusNotFound)
 		return
@@ -1133,7 +1161,7 @@ func networkDisconnect(c *context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	container := c.cluster.Container(disconnect.Container)
+	container := c.container(disconnect.Container)
 	if container == nil {
 		httpError(w, fmt.Sprintf("No such container: %s", disconnect.Container), http.StatusNotFound)
 		return
@@ -1169,7 +1197,7 @@ func networkDisconnect(c *context, w http.ResponseWriter, r *http.Request) {
 // POST /networks/{networkid:.*}/connect
 func proxyNetworkConnect(c *context, w http.ResponseWriter, r *http.Request) {
 	var networkid = mux.Vars(r)["networkid"]
-	network := c.cluster.Networks().Uniq().Get(networkid)
+	network := c.network(networkid)
 	if network == nil {
 		httpError(w, fmt.Sprintf("No such network: %s", networkid), http.StatusNotFound)
 		return
@@ -1190,7 +1218,7 @@ func proxyNetworkConnect(c *context, w http.ResponseWriter, r *http.Request) {
 		httpError(w, "Container is not specified", http.StatusNotFound)
 		return
 	}
-	container := c.cluster.Container(connect.Container)
+	container := c.container(connect.Container)
 	if container == nil {
 		httpError(w, fmt.Sprintf("No such container: %s", connect.Container), http.StatusNotFound)
 		return
@@ -1670,3 +1698,193 @@ func stripNodeNamesFromNetworkingConfig(n network.NetworkingConfig, nodeNames []
 	n.EndpointsConfig = endpointsConfig
 	return n
 }
+
+// POST /containers/{name:.*}/update
+func postContainerUpdate(c *context, w http.ResponseWriter, r *http.Request) {
+	_, container, err := getContainerFromVars(c, mux.Vars(r))
+	if err != nil {
+		if container == nil {
+			httpError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	update := containertypes.UpdateConfig{}
+	if err := json.Unmarshal(body, &update); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := c.cluster.CheckContainerUpdate(container, update); err != nil {
+		if isForbidden(err) {
+			httpError(w, err.Error(), http.StatusForbidden)
+		} else {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// The body was consumed, hand a copy of it to the proxy.
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	proxyContainerAndForceRefresh(c, w, r)
+}
+
+// GET /quotas
+func getQuotas(c *context, w http.ResponseWriter, r *http.Request) {
+	quotas, err := c.cluster.Quotas()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	// Confined clients only see the quota of their namespace.
+	if c.namespace != "" {
+		visible := []cluster.QuotaUsage{}
+		for _, quota := range quotas {
+			if quota.Tenant == c.namespace {
+				visible = append(visible, quota)
+			}
+		}
+		quotas = visible
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quotas)
+}
+
+// GET /quotas/{tenant:.*}
+func getQuota(c *context, w http.ResponseWriter, r *http.Request) {
+	tenant := mux.Vars(r)["tenant"]
+	if c.namespace != "" && tenant != c.namespace {
+		httpError(w, "confined clients can only see the quota of their namespace", http.StatusForbidden)
+		return
+	}
+	quotas, err := c.cluster.Quotas()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	usage := cluster.QuotaUsage{Tenant: tenant}
+	for _, quota := range quotas {
+		if quota.Tenant == tenant {
+			usage = quota
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+// POST /quotas/{tenant:.*}
+func postQuota(c *context, w http.ResponseWriter, r *http.Request) {
+	if c.namespace != "" {
+		httpError(w, "quotas can only be managed by unrestricted clients", http.StatusForbidden)
+		return
+	}
+
+	tenant := mux.Vars(r)["tenant"]
+	if tenant == "" {
+		httpError(w, "tenant is required", http.StatusBadRequest)
+		return
+	}
+
+	quota := cluster.Quota{}
+	if err := json.NewDecoder(r.Body).Decode(&quota); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if quota.Containers < 0 || quota.Memory < 0 || quota.Cpus < 0 {
+		httpError(w, "quota values can't be negative", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.cluster.SetQuota(tenant, &quota); err != nil {
+		httpError(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /quotas/{tenant:.*}
+func deleteQuota(c *context, w http.ResponseWriter, r *http.Request) {
+	if c.namespace != "" {
+		httpError(w, "quotas can only be managed by unrestricted clients", http.StatusForbidden)
+		return
+	}
+
+	if err := c.cluster.SetQuota(mux.Vars(r)["tenant"], nil); err != nil {
+		httpError(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /credentials
+func getRegistryCredentials(c *context, w http.ResponseWriter, r *http.Request) {
+	if c.namespace != "" {
+		httpError(w, "registry credentials can only be managed by unrestricted clients", http.StatusForbidden)
+		return
+	}
+
+	registries, err := c.cluster.RegistryCredentials()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	// Only the registries are listed, never the credentials.
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(registries)
+}
+
+// POST /credentials/{registry:.*}
+func postRegistryCredentials(c *context, w http.ResponseWriter, r *http.Request) {
+	if c.namespace != "" {
+		httpError(w, "registry credentials can only be managed by unrestricted clients", http.StatusForbidden)
+		return
+	}
+
+	registry := mux.Vars(r)["registry"]
+	if registry == "" {
+		httpError(w, "registry is required", http.StatusBadRequest)
+		return
+	}
+
+	auth := apitypes.AuthConfig{}
+	if err := json.NewDecoder(r.Body).Decode(&auth); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if auth == (apitypes.AuthConfig{}) {
+		httpError(w, "credentials are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.cluster.SetRegistryCredentials(registry, &auth); err != nil {
+		httpError(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /credentials/{registry:.*}
+func deleteRegistryCredentials(c *context, w http.ResponseWriter, r *http.Request) {
+	if c.namespace != "" {
+		httpError(w, "registry credentials can only be managed by unrestricted clients", http.StatusForbidden)
+		return
+	}
+
+	if err := c.cluster.SetRegistryCredentials(mux.Vars(r)["registry"], nil); err != nil {
+		httpError(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
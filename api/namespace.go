@@ -0,0 +1,223 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/docker/swarm/cluster"
+)
+
+// namespaceLabel is the label recording the namespace of the containers,
+// networks and volumes created through the API.
+const namespaceLabel = cluster.SwarmLabelNamespace + ".namespace"
+
+// allNamespaces is the namespace of the identities which see everything.
+const allNamespaces = "*"
+
+// Namespaces maps client identities to the namespace they are confined to.
+type Namespaces struct {
+	identities map[string]string
+}
+
+// LoadNamespaces reads a JSON object mapping client identities to namespaces
+// from a file, such as {"alice": "web", "ci": "ci", "admin": "*"}.
+func LoadNamespaces(path string) (*Namespaces, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	identities := make(map[string]string)
+	if err := json.Unmarshal(data, &identities); err != nil {
+		return nil, fmt.Errorf("invalid namespaces: %v", err)
+	}
+	for identity, namespace := range identities {
+		if namespace == "" {
+			return nil, fmt.Errorf("invalid namespaces: empty namespace for %s", identity)
+		}
+	}
+	return &Namespaces{identities: identities}, nil
+}
+
+// namespace returns the namespace of the client sending r. An empty
+// namespace gives access to every object.
+func (n *Namespaces) namespace(r *http.Request) (string, error) {
+	identity := requestIdentity(r)
+	if identity == "" {
+		return "", errors.New("unable to identify the client")
+	}
+	namespace, ok := n.identities[identity]
+	if !ok {
+		return "", fmt.Errorf("%s is not allowed to use this cluster", identity)
+	}
+	if namespace == allNamespaces {
+		return "", nil
+	}
+	return namespace, nil
+}
+
+// requestIdentity returns the identity of the client sending r: the
+// identity of its API key or token, or else the common name of its TLS
+// certificate, as forwarded by the replica which received r if any.
+func requestIdentity(r *http.Request) string {
+	if identity := authenticatedIdentity(r); identity != "" {
+		return identity
+	}
+	if client := forwarded(r); client != nil {
+		return client.commonName
+	}
+	return certCommonName(r)
+}
+
+// forRequest returns the context of a request, confined to the namespace of
+// its client when namespaces are enabled.
+func (c *context) forRequest(r *http.Request) (*context, error) {
+	if c.namespaces == nil {
+		return c, nil
+	}
+	namespace, err := c.namespaces.namespace(r)
+	if err != nil {
+		return nil, err
+	}
+	requestContext := *c
+	requestContext.namespace = namespace
+	return &requestContext, nil
+}
+
+// owns returns true if the object with the given labels belongs to the
+// namespace of the request.
+func (c *context) owns(labels map[string]string) bool {
+	return c.namespace == "" || labels[namespaceLabel] == c.namespace
+}
+
+// canSee returns true if the network or volume with the given labels belongs
+// to the namespace of the request, or to no namespace at all.
+func (c *context) canSee(labels map[string]string) bool {
+	namespace, ok := labels[namespaceLabel]
+	return !ok || c.owns(labels) || namespace == ""
+}
+
+// setNamespace records the namespace of the request in the labels of a new
+// object.
+func (c *context) setNamespace(labels map[string]string) map[string]string {
+	if c.namespace == "" {
+		return labels
+	}
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[namespaceLabel] = c.namespace
+	return labels
+}
+
+// container returns the container matching IDOrName, if it belongs to the
+// namespace of the request.
+func (c *context) container(IDOrName string) *cluster.Container {
+	container := c.cluster.Container(IDOrName)
+	if container == nil || !c.ownsContainer(container) {
+		return nil
+	}
+	return container
+}
+
+// ownsContainer returns true if the container belongs to the namespace of the
+// request.
+func (c *context) ownsContainer(container *cluster.Container) bool {
+	if container.Config != nil {
+		return c.owns(container.Config.Labels)
+	}
+	return c.owns(container.Labels)
+}
+
+// network returns the network matching IDOrName, if it is visible in the
+// namespace of the request.
+func (c *context) network(IDOrName string) *cluster.Network {
+	network := c.cluster.Networks().Uniq().Get(IDOrName)
+	if network == nil || !c.canSee(network.Labels) {
+		return nil
+	}
+	return network
+}
+
+// ownsEvent returns true if the event is about a container of the namespace
+// of the request, a network or a volume visible in this namespace, or an
+// image. The events of the engines are only sent to unrestricted clients.
+func (c *context) ownsEvent(e *cluster.Event) bool {
+	if c.namespace == "" {
+		return true
+	}
+	switch e.Type {
+	case "container":
+		// The events of the containers carry their labels.
+		return c.owns(e.Actor.Attributes)
+	case "network":
+		return c.network(e.Actor.ID) != nil
+	case "volume":
+		return c.volume(e.Actor.ID) != nil
+	case "image":
+		return true
+	}
+	return false
+}
+
+// checkReferences returns an error if a new container refers to a container,
+// a network or a named volume outside of the namespace of the request,
+// through --volumes-from, --link, --net, --ipc=container:, --pid=container:,
+// the endpoints of its networking config or -v.
+func (c *context) checkReferences(config *cluster.ContainerConfig) error {
+	if c.namespace == "" {
+		return nil
+	}
+	containers := []string{}
+	for _, volumesFrom := range config.HostConfig.VolumesFrom {
+		containers = append(containers, strings.SplitN(volumesFrom, ":", 2)[0])
+	}
+	for _, link := range config.HostConfig.Links {
+		containers = append(containers, strings.TrimPrefix(strings.SplitN(link, ":", 2)[0], "/"))
+	}
+	if config.HostConfig.NetworkMode.IsContainer() {
+		containers = append(containers, config.HostConfig.NetworkMode.ConnectedContainer())
+	}
+	if config.HostConfig.IpcMode.IsContainer() {
+		containers = append(containers, config.HostConfig.IpcMode.Container())
+	}
+	if config.HostConfig.PidMode.IsContainer() {
+		containers = append(containers, config.HostConfig.PidMode.Container())
+	}
+	for _, name := range containers {
+		if c.cluster.Container(name) != nil && c.container(name) == nil {
+			return fmt.Errorf("No such container: %s", name)
+		}
+	}
+	networks := []string{}
+	if config.HostConfig.NetworkMode.IsUserDefined() {
+		networks = append(networks, config.HostConfig.NetworkMode.NetworkName())
+	}
+	for name := range config.NetworkingConfig.EndpointsConfig {
+		networks = append(networks, name)
+	}
+	for _, name := range networks {
+		if c.cluster.Networks().Uniq().Get(name) != nil && c.network(name) == nil {
+			return fmt.Errorf("No such network: %s", name)
+		}
+	}
+	for _, name := range config.NamedVolumes() {
+		if c.cluster.Volumes().Get(name) != nil && c.volume(name) == nil {
+			return fmt.Errorf("No such volume: %s", name)
+		}
+	}
+	return nil
+}
+
+// volume returns the volume matching name, if it is visible in the namespace
+// of the request.
+func (c *context) volume(name string) *cluster.Volume {
+	volume := c.cluster.Volumes().Get(name)
+	if volume == nil || !c.canSee(volume.Labels) {
+		return nil
+	}
+	return volume
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/docker/swarm/cluster"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// containerCluster is a cluster only able to look up containers, volumes,
+// networks and quotas.
+type containerCluster struct {
+	cluster.Cluster
+	containers map[string]*cluster.Container
+	volumes    cluster.Volumes
+	networks   cluster.Networks
+	quotas     []cluster.QuotaUsage
+}
+
+func (c *containerCluster) Container(IDOrName string) *cluster.Container {
+	return c.containers[IDOrName]
+}
+
+func (c *containerCluster) Volumes() cluster.Volumes {
+	return c.volumes
+}
+
+func (c *containerCluster) Networks() cluster.Networks {
+	return c.networks
+}
+
+func (c *containerCluster) Quotas() ([]cluster.QuotaUsage, error) {
+	return c.quotas, nil
+}
+
+func requestFrom(identity string) *http.Request {
+	r := httptest.NewRequest("GET", "/version", nil)
+	if identity != "" {
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: identity}}}}
+	}
+	return r
+}
+
+func TestNamespaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-namespaces")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "namespaces.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"alice": "web", "bob": "batch", "admin": "*"}`), 0644))
+
+	namespaces, err := LoadNamespaces(path)
+	assert.NoError(t, err)
+
+	newContainer := func(ID, namespace string) *cluster.Container {
+		labels := map[string]string{}
+		if namespace != "" {
+			labels[namespaceLabel] = namespace
+		}
+		return &cluster.Container{
+			Container: types.Container{ID: ID, Labels: labels},
+			Config:    cluster.BuildContainerConfig(containertypes.Config{Labels: labels}, containertypes.HostConfig{}, networktypes.NetworkingConfig{}),
+		}
+	}
+	base := &context{
+		cluster: &containerCluster{containers: map[string]*cluster.Container{
+			"web-id":   newContainer("web-id", "web"),
+			"batch-id": newContainer("batch-id", "batch"),
+			"other-id": newContainer("other-id", ""),
+		}},
+		namespaces: namespaces,
+	}
+
+	alice, err := base.forRequest(requestFrom("alice"))
+	assert.NoError(t, err)
+	assert.Equal(t, "web", alice.namespace)
+	assert.NotNil(t, alice.container("web-id"))
+	assert.Nil(t, alice.container("batch-id"))
+	assert.Nil(t, alice.container("other-id"))
+	assert.Equal(t, map[string]string{namespaceLabel: "web", "app": "db"}, alice.setNamespace(map[string]string{namespaceLabel: "batch", "app": "db"}))
+
+	// Networks and volumes outside of any namespace are shared.
+	assert.True(t, alice.canSee(map[string]string{}))
+	assert.True(t, alice.canSee(map[string]string{namespaceLabel: "web"}))
+	assert.False(t, alice.canSee(map[string]string{namespaceLabel: "batch"}))
+
+	admin, err := base.forRequest(requestFrom("admin"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", admin.namespace)
+	assert.NotNil(t, admin.container("batch-id"))
+	assert.NotNil(t, admin.container("other-id"))
+	assert.Nil(t, admin.setNamespace(nil))
+
+	_, err = base.forRequest(requestFrom("mallory"))
+	assert.Error(t, err)
+	_, err = base.forRequest(requestFrom(""))
+	assert.Error(t, err)
+
+	// Unknown clients are refused by the router.
+	router := mux.NewRouter()
+	setupPrimaryRouter(router, base, false)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, requestFrom("mallory"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestNamespaceReferences(t *testing.T) {
+	engine := &cluster.Engine{ID: "node-id", Name: "node"}
+	newVolume := func(name, namespace string) *cluster.Volume {
+		return &cluster.Volume{Volume: types.Volume{Name: name, Labels: map[string]string{namespaceLabel: namespace}}, Engine: engine}
+	}
+	newNetwork := func(name, namespace string) *cluster.Network {
+		labels := map[string]string{}
+		if namespace != "" {
+			labels[namespaceLabel] = namespace
+		}
+		return &cluster.Network{NetworkResource: types.NetworkResource{ID: name + "-id", Name: name, Labels: labels}, Engine: engine}
+	}
+	c := &context{
+		cluster: &containerCluster{
+			containers: map[string]*cluster.Container{
+				"web": {Container: types.Container{ID: "web", Labels: map[string]string{namespaceLabel: "web"}}},
+				"db":  {Container: types.Container{ID: "db", Labels: map[string]string{namespaceLabel: "batch"}}},
+			},
+			volumes:  cluster.Volumes{newVolume("web-data", "web"), newVolume("db-data", "batch")},
+			networks: cluster.Networks{newNetwork("web-net", "web"), newNetwork("db-net", "batch"), newNetwork("shared", "")},
+		},
+		namespace: "web",
+	}
+
+	checkNetworks := func(hostConfig containertypes.HostConfig, endpoints ...string) error {
+		networkingConfig := networktypes.NetworkingConfig{EndpointsConfig: map[string]*networktypes.EndpointSettings{}}
+		for _, endpoint := range endpoints {
+			networkingConfig.EndpointsConfig[endpoint] = &networktypes.EndpointSettings{}
+		}
+		return c.checkReferences(cluster.BuildContainerConfig(containertypes.Config{}, hostConfig, networkingConfig))
+	}
+	check := func(hostConfig containertypes.HostConfig) error {
+		return checkNetworks(hostConfig)
+	}
+	assert.NoError(t, check(containertypes.HostConfig{
+		VolumesFrom: []string{"web:ro"},
+		Links:       []string{"/web:app"},
+		NetworkMode: "container:web",
+		IpcMode:     "container:web",
+		PidMode:     "container:web",
+		Binds:       []string{"web-data:/data", "new-data:/new"},
+	}))
+	assert.Error(t, check(containertypes.HostConfig{VolumesFrom: []string{"db:ro"}}))
+	assert.Error(t, check(containertypes.HostConfig{Links: []string{"db:db"}}))
+	assert.Error(t, check(containertypes.HostConfig{NetworkMode: "container:db"}))
+	assert.Error(t, check(containertypes.HostConfig{IpcMode: "container:db"}))
+	assert.Error(t, check(containertypes.HostConfig{PidMode: "container:db"}))
+	assert.Error(t, check(containertypes.HostConfig{Binds: []string{"db-data:/data"}}))
+	assert.NoError(t, checkNetworks(containertypes.HostConfig{NetworkMode: "web-net"}, "web-net", "shared", "new-net"))
+	assert.NoError(t, check(containertypes.HostConfig{NetworkMode: "bridge"}))
+	assert.Error(t, check(containertypes.HostConfig{NetworkMode: "db-net"}))
+	assert.Error(t, check(containertypes.HostConfig{NetworkMode: "db-net-id"}))
+	assert.Error(t, checkNetworks(containertypes.HostConfig{NetworkMode: "web-net"}, "web-net", "db-net"))
+
+	// Unrestricted clients can refer to everything.
+	c.namespace = ""
+	assert.NoError(t, check(containertypes.HostConfig{VolumesFrom: []string{"db"}, Binds: []string{"db-data:/data"}}))
+}
+
+func TestNamespaceEvents(t *testing.T) {
+	c := &context{cluster: &containerCluster{}, namespace: "web"}
+	newEvent := func(eventType string, attributes map[string]string) *cluster.Event {
+		return &cluster.Event{Message: events.Message{Type: eventType, Actor: events.Actor{ID: "id", Attributes: attributes}}}
+	}
+
+	assert.True(t, c.ownsEvent(newEvent("container", map[string]string{namespaceLabel: "web"})))
+	assert.False(t, c.ownsEvent(newEvent("container", map[string]string{namespaceLabel: "batch"})))
+	assert.False(t, c.ownsEvent(newEvent("container", map[string]string{})))
+	assert.False(t, c.ownsEvent(newEvent("volume", nil)))
+	assert.True(t, c.ownsEvent(newEvent("image", nil)))
+	assert.False(t, c.ownsEvent(newEvent("daemon", nil)))
+
+	c.namespace = ""
+	assert.True(t, c.ownsEvent(newEvent("daemon", nil)))
+}
+
+func TestNamespaceQuotas(t *testing.T) {
+	c := &context{cluster: &containerCluster{quotas: []cluster.QuotaUsage{
+		{Tenant: "batch", Quota: &cluster.Quota{Containers: 10}},
+		{Tenant: "web", Quota: &cluster.Quota{Containers: 2}},
+	}}}
+	get := func(namespace, path string) (int, []byte) {
+		c.namespace = namespace
+		router := mux.NewRouter()
+		router.HandleFunc("/quotas", func(w http.ResponseWriter, r *http.Request) { getQuotas(c, w, r) })
+		router.HandleFunc("/quotas/{tenant:.*}", func(w http.ResponseWriter, r *http.Request) { getQuota(c, w, r) })
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Code, w.Body.Bytes()
+	}
+
+	// Confined clients only see the quota of their namespace.
+	code, body := get("web", "/quotas")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `[{"Tenant":"web","Quota":{"Containers":2,"Memory":0,"Cpus":0},"Used":{"Containers":0,"Memory":0,"Cpus":0}}]`, string(body))
+	code, _ = get("web", "/quotas/web")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = get("web", "/quotas/batch")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, body = get("", "/quotas")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, string(body), "batch")
+}
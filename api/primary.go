@@ -19,6 +19,23 @@ type context struct {
 	debug         bool
 	tlsConfig     *tls.Config
 	apiVersion    string
+	// managerIdentity is the common name of the certificate of the
+	// managers, trusted to forward the clients of the replicas.
+	managerIdentity string
+
+	// namespaces confines clients to their namespace, if set. namespace is
+	// the namespace of the current request, empty when unrestricted.
+	namespaces *Namespaces
+	namespace  string
+
+	// authentication identifies clients by API key or token, if set.
+	authentication *Authentication
+	// authorization restricts the routes clients can use, if set.
+	authorization *Authorization
+	// audit records the requests changing the cluster, if set.
+	audit *Audit
+	// rateLimiter limits the requests of each client, if set.
+	rateLimiter *RateLimiter
 }
 
 type handler func(c *context, w http.ResponseWriter, r *http.Request)
@@ -54,6 +71,9 @@ var routes = map[string]map[string]handler{
 		"/networks/{networkid:.*}":        getNetwork,
 		"/volumes":                        getVolumes,
 		"/volumes/{volumename:.*}":        getVolume,
+		"/quotas":                         getQuotas,
+		"/quotas/{tenant:.*}":             getQuota,
+		"/credentials":                    getRegistryCredentials,
 	},
 	"POST": {
 		"/auth":                               proxyRandom,
@@ -72,7 +92,7 @@ var routes = map[string]map[string]handler{
 		"/containers/{name:.*}/restart":       proxyContainerAndForceRefresh,
 		"/containers/{name:.*}/start":         postContainersStart,
 		"/containers/{name:.*}/stop":          proxyContainerAndForceRefresh,
-		"/containers/{name:.*}/update":        proxyContainerAndForceRefresh,
+		"/containers/{name:.*}/update":        postContainerUpdate,
 		"/containers/{name:.*}/wait":          postContainersWait,
 		"/containers/{name:.*}/resize":        proxyContainer,
 		"/containers/{name:.*}/attach":        proxyHijack,
@@ -84,6 +104,8 @@ var routes = map[string]map[string]handler{
 		"/networks/{networkid:.*}/connect":    proxyNetworkConnect,
 		"/networks/{networkid:.*}/disconnect": networkDisconnect,
 		"/volumes/create":                     postVolumesCreate,
+		"/quotas/{tenant:.*}":                 postQuota,
+		"/credentials/{registry:.*}":          postRegistryCredentials,
 
 		// TODO(dperny): this route is WIP, remove this comment
 		"/session": postSession,
@@ -92,10 +114,12 @@ var routes = map[string]map[string]handler{
 		"/containers/{name:.*}/archive": proxyContainer,
 	},
 	"DELETE": {
-		"/containers/{name:.*}":    deleteContainers,
-		"/images/{name:.*}":        deleteImages,
-		"/networks/{networkid:.*}": deleteNetworks,
-		"/volumes/{name:.*}":       deleteVolumes,
+		"/containers/{name:.*}":      deleteContainers,
+		"/images/{name:.*}":          deleteImages,
+		"/networks/{networkid:.*}":   deleteNetworks,
+		"/volumes/{name:.*}":         deleteVolumes,
+		"/quotas/{tenant:.*}":        deleteQuota,
+		"/credentials/{registry:.*}": deleteRegistryCredentials,
 	},
 }
 
@@ -119,7 +143,7 @@ func profilerSetup(mainRouter *mux.Router, path string) {
 }
 
 // NewPrimary creates a new API router.
-func NewPrimary(cluster cluster.Cluster, tlsConfig *tls.Config, status StatusHandler, debug, enableCors bool) *mux.Router {
+func NewPrimary(cluster cluster.Cluster, tlsConfig *tls.Config, status StatusHandler, namespaces *Namespaces, authentication *Authentication, authorization *Authorization, audit *Audit, rateLimiter *RateLimiter, debug, enableCors bool) *mux.Router {
 	// Register the API events handler in the cluster.
 
 	// eventsHandler is the handler for API events
@@ -127,10 +151,16 @@ func NewPrimary(cluster cluster.Cluster, tlsConfig *tls.Config, status StatusHan
 	cluster.RegisterEventHandler(eventsHandler)
 
 	context := &context{
-		cluster:       cluster,
-		eventsHandler: eventsHandler,
-		statusHandler: status,
-		tlsConfig:     tlsConfig,
+		cluster:         cluster,
+		eventsHandler:   eventsHandler,
+		statusHandler:   status,
+		tlsConfig:       tlsConfig,
+		managerIdentity: managerIdentity(tlsConfig),
+		namespaces:      namespaces,
+		authentication:  authentication,
+		authorization:   authorization,
+		audit:           audit,
+		rateLimiter:     rateLimiter,
 	}
 
 	r := mux.NewRouter()
@@ -150,6 +180,7 @@ func setupPrimaryRouter(r *mux.Router, context *context, enableCors bool) {
 
 			localRoute := route
 			localFct := fct
+			localMethod := method
 
 			wrap := func(w http.ResponseWriter, r *http.Request) {
 				log.WithFields(log.Fields{"method": r.Method, "uri": r.RequestURI}).Debug("HTTP request received")
@@ -158,9 +189,45 @@ func setupPrimaryRouter(r *mux.Router, context *context, enableCors bool) {
 				}
 				context.apiVersion = mux.Vars(r)["version"]
 				w.Header().Set("API-Version", APIVERSION)
-				localFct(context, w, r)
+				context.trustForwarded(r)
+				if context.audit != nil && r.Method != "GET" && r.Method != "HEAD" {
+					recorder := &auditResponseWriter{ResponseWriter: w}
+					w = recorder
+					defer context.audit.end(context.audit.begin(context, r, localRoute), r, recorder)
+				}
+				if context.rateLimiter != nil {
+					release, retryAfter, err := context.rateLimiter.admit(requestClient(r), localMethod, localRoute)
+					if err != nil {
+						log.WithFields(log.Fields{"method": r.Method, "uri": r.RequestURI}).WithError(err).Warn("Rate limited request")
+						tooManyRequests(w, retryAfter, err)
+						return
+					}
+					defer release()
+				}
+				if context.authentication != nil {
+					if err := context.authentication.authenticate(r); err != nil {
+						log.WithFields(log.Fields{"method": r.Method, "uri": r.RequestURI}).WithError(err).Warn("Unauthenticated request")
+						unauthorized(w, err)
+						return
+					}
+					// The credentials are for the manager, don't pass
+					// them on to the engines.
+					r.Header.Del("Authorization")
+				}
+				if context.authorization != nil {
+					if err := context.authorization.authorize(r, localMethod, localRoute); err != nil {
+						log.WithFields(log.Fields{"method": r.Method, "uri": r.RequestURI}).WithError(err).Warn("Unauthorized request")
+						forbidden(w, err)
+						return
+					}
+				}
+				requestContext, err := context.forRequest(r)
+				if err != nil {
+					forbidden(w, err)
+					return
+				}
+				localFct(requestContext, w, r)
 			}
-			localMethod := method
 
 			r.Path("/v{version:[0-9]+.[0-9]+}" + localRoute).Methods(localMethod).HandlerFunc(wrap)
 			r.Path(localRoute).Methods(localMethod).HandlerFunc(wrap)
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The classes of routes limited together.
+const (
+	readRoutes   = "read"
+	writeRoutes  = "write"
+	streamRoutes = "stream"
+)
+
+// streamingRoutes are the routes holding their connection open to stream
+// their response.
+var streamingRoutes = map[string]bool{
+	"/events":                         true,
+	"/containers/{name:.*}/logs":      true,
+	"/containers/{name:.*}/stats":     true,
+	"/containers/{name:.*}/attach":    true,
+	"/containers/{name:.*}/attach/ws": true,
+	"/containers/{name:.*}/wait":      true,
+	"/exec/{execid:.*}/start":         true,
+}
+
+// rateLimitSweepInterval is the interval at which idle clients are forgotten.
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit limits the requests of each client on a class of routes: Rate
+// requests per second with bursts of Burst requests, and Concurrent requests
+// at once. Zero values are unlimited.
+type RateLimit struct {
+	Rate       float64
+	Burst      int
+	Concurrent int
+}
+
+// ParseRateLimit parses a rate limit of a class of routes, formatted as
+// "class=read,rate=20,burst=40" or "class=stream,concurrent=5". The class is
+// read, write or stream. The burst defaults to the rate.
+func ParseRateLimit(value string) (string, RateLimit, error) {
+	class := ""
+	limit := RateLimit{}
+	for _, option := range strings.Split(value, ",") {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 {
+			return "", limit, fmt.Errorf("invalid rate limit option %q, expected key=value", option)
+		}
+		var err error
+		switch kv[0] {
+		case "class":
+			class = kv[1]
+		case "rate":
+			limit.Rate, err = strconv.ParseFloat(kv[1], 64)
+		case "burst":
+			limit.Burst, err = strconv.Atoi(kv[1])
+		case "concurrent":
+			limit.Concurrent, err = strconv.Atoi(kv[1])
+		default:
+			return "", limit, fmt.Errorf("unknown rate limit option %q", kv[0])
+		}
+		if err != nil {
+			return "", limit, fmt.Errorf("invalid rate limit option %q: %v", option, err)
+		}
+	}
+	switch class {
+	case readRoutes, writeRoutes, streamRoutes:
+	default:
+		return "", limit, fmt.Errorf("invalid rate limit class %q, expected read, write or stream", class)
+	}
+	if limit.Rate < 0 || limit.Burst < 0 || limit.Concurrent < 0 {
+		return "", limit, fmt.Errorf("invalid rate limit %q: negative value", value)
+	}
+	if limit.Rate > 0 && limit.Burst == 0 {
+		limit.Burst = int(math.Ceil(limit.Rate))
+	}
+	return class, limit, nil
+}
+
+// tokenBucket holds the tokens of a client, refilled over time.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter limits the requests of each client, identified by its identity
+// or else its IP address.
+type RateLimiter struct {
+	mu        sync.Mutex
+	limits    map[string]RateLimit
+	buckets   map[string]*tokenBucket
+	active    map[string]int
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter creates a rate limiter without limits.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		limits:  make(map[string]RateLimit),
+		buckets: make(map[string]*tokenBucket),
+		active:  make(map[string]int),
+		now:     time.Now,
+	}
+}
+
+// SetLimit sets the limit of a class of routes.
+func (l *RateLimiter) SetLimit(class string, limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits[class] = limit
+}
+
+// routeClass returns the class of a route.
+func routeClass(method, route string) string {
+	switch {
+	case streamingRoutes[route]:
+		return streamRoutes
+	case method == "GET" || method == "HEAD":
+		return readRoutes
+	default:
+		return writeRoutes
+	}
+}
+
+// admit admits a request of client on route, unless it exceeds the limits
+// of the route class. release must be called once the request is handled.
+// retryAfter is the time to wait before retrying a request not admitted.
+func (l *RateLimiter) admit(client, method, route string) (release func(), retryAfter time.Duration, err error) {
+	class := routeClass(method, route)
+	key := class + "/" + client
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limit := l.limits[class]
+	now := l.now()
+	l.sweep(now)
+
+	if limit.Concurrent > 0 && l.active[key] >= limit.Concurrent {
+		return nil, time.Second, fmt.Errorf("too many concurrent %s requests from %s, the limit is %d", class, client, limit.Concurrent)
+	}
+	if limit.Rate > 0 {
+		bucket, ok := l.buckets[key]
+		if !ok {
+			bucket = &tokenBucket{tokens: float64(limit.Burst), last: now}
+			l.buckets[key] = bucket
+		}
+		bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate)
+		bucket.last = now
+		if bucket.tokens < 1 {
+			wait := time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+			return nil, wait, fmt.Errorf("too many %s requests from %s, the limit is %g per second", class, client, limit.Rate)
+		}
+		bucket.tokens--
+	}
+
+	if limit.Concurrent == 0 {
+		return func() {}, 0, nil
+	}
+	l.active[key]++
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.active[key]--; l.active[key] <= 0 {
+			delete(l.active, key)
+		}
+	}, 0, nil
+}
+
+// sweep forgets the buckets of the clients idle long enough to be full
+// again.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		limit := l.limits[key[:strings.Index(key, "/")]]
+		if limit.Rate == 0 || bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// requestClient returns the client limited for r, known before r is
+// authenticated so that failed attempts are limited as well: the identity of
+// its verified TLS certificate, or else its IP address, as forwarded by the
+// replica which received r if any.
+func requestClient(r *http.Request) string {
+	if client := forwarded(r); client != nil {
+		if client.commonName != "" {
+			return client.commonName
+		}
+	} else if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && certCommonName(r) != "" {
+		return certCommonName(r)
+	}
+	addr := remoteAddr(r)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// tooManyRequests sends a Docker style error with a 429 status.
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration, err error) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	class, limit, err := ParseRateLimit("class=read,rate=20,burst=40")
+	assert.NoError(t, err)
+	assert.Equal(t, "read", class)
+	assert.Equal(t, RateLimit{Rate: 20, Burst: 40}, limit)
+
+	class, limit, err = ParseRateLimit("class=write,rate=0.5")
+	assert.NoError(t, err)
+	assert.Equal(t, "write", class)
+	assert.Equal(t, RateLimit{Rate: 0.5, Burst: 1}, limit)
+
+	class, limit, err = ParseRateLimit("class=stream,concurrent=5")
+	assert.NoError(t, err)
+	assert.Equal(t, "stream", class)
+	assert.Equal(t, RateLimit{Concurrent: 5}, limit)
+
+	for _, value := range []string{"rate=20", "class=other,rate=1", "class=read,rate=fast", "class=read,speed=1", "class=read,rate=-1", "class"} {
+		_, _, err := ParseRateLimit(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewRateLimiter()
+	l.now = func() time.Time { return now }
+	l.SetLimit(readRoutes, RateLimit{Rate: 1, Burst: 2})
+	l.SetLimit(streamRoutes, RateLimit{Concurrent: 1})
+
+	admit := l.admit
+
+	// The bucket allows a burst, then one request per second.
+	for i := 0; i < 2; i++ {
+		_, _, err := admit("ci", "GET", "/containers/json")
+		assert.NoError(t, err)
+	}
+	_, retryAfter, err := admit("ci", "GET", "/containers/json")
+	assert.Error(t, err)
+	assert.Equal(t, time.Second, retryAfter)
+	_, _, err = admit("alice", "GET", "/containers/json")
+	assert.NoError(t, err)
+	_, _, err = admit("ci", "POST", "/containers/create")
+	assert.NoError(t, err)
+	now = now.Add(time.Second)
+	_, _, err = admit("ci", "GET", "/containers/json")
+	assert.NoError(t, err)
+
+	// Streams are limited in number.
+	release, _, err := admit("ci", "GET", "/events")
+	assert.NoError(t, err)
+	_, _, err = admit("ci", "GET", "/containers/{name:.*}/logs")
+	assert.Error(t, err)
+	release()
+	_, _, err = admit("ci", "GET", "/containers/{name:.*}/logs")
+	assert.NoError(t, err)
+
+	// Idle clients are forgotten.
+	now = now.Add(time.Hour)
+	l.sweep(now)
+	assert.Empty(t, l.buckets)
+}
+
+func TestRateLimitedRequest(t *testing.T) {
+	l := NewRateLimiter()
+	l.SetLimit(readRoutes, RateLimit{Rate: 0.1, Burst: 1})
+	router := mux.NewRouter()
+	setupPrimaryRouter(router, &context{rateLimiter: l}, false)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/_ping", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/_ping", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "too many read requests from 192.0.2.1")
+
+	// Failed authentications are limited as well.
+	authentication := &Authentication{Keys: map[string]string{"reader": "r3ad"}}
+	router = mux.NewRouter()
+	setupPrimaryRouter(router, &context{rateLimiter: l, authentication: authentication}, false)
+	for _, code := range []int{http.StatusUnauthorized, http.StatusTooManyRequests} {
+		w = httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/_ping", nil)
+		r.RemoteAddr = "192.0.2.2:1234"
+		r.Header.Set("Authorization", "Bearer wrong")
+		router.ServeHTTP(w, r)
+		assert.Equal(t, code, w.Code)
+	}
+
+	// Behind a replica, the clients are told apart by their forwarded
+	// address.
+	c := &context{managerIdentity: "manager"}
+	for _, addr := range []string{"10.0.0.1", "10.0.0.2"} {
+		r := requestFrom("manager")
+		r.TLS.VerifiedChains = [][]*x509.Certificate{r.TLS.PeerCertificates}
+		r.Header.Set(forwardedForHeader, addr)
+		c.trustForwarded(r)
+		assert.Equal(t, addr, requestClient(r))
+	}
+}
@@ -136,6 +136,11 @@ func (p *Replica) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		p.handler.ServeHTTP(w, r)
 		return
 	}
+	// The request is forwarded with its headers, the primary authenticates
+	// the clients sending an API key or token in the Authorization header.
+	// The identity of the TLS certificate and the address of the client are
+	// lost on the way, so they are forwarded in headers as well.
+	forwardClient(r)
 	if err := p.hijack(p.tlsConfig, primary, w, r); err != nil {
 		httpError(w, fmt.Sprintf("Unable to reach primary cluster manager (%s): %v", err, p.primary), http.StatusInternalServerError)
 	}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	dockerfilters "github.com/docker/docker/api/types/filters"
 	"github.com/docker/swarm/cluster"
 )
 
@@ -25,6 +26,16 @@ func httpError(w http.ResponseWriter, err string, status int) {
 	http.Error(w, err, status)
 }
 
+// isForbidden returns true if err denies a request, such as a container
+// refused by an admission webhook, a policy or a quota.
+func isForbidden(err error) bool {
+	switch err.(type) {
+	case *cluster.AdmissionError, *cluster.PolicyError, *cluster.QuotaError:
+		return true
+	}
+	return false
+}
+
 func sendErrorJSONMessage(w io.Writer, errorCode int, errorMessage string) {
 	error := struct {
 		Code    int    `json:"code,omitempty"`
@@ -47,7 +58,7 @@ func sendErrorJSONMessage(w io.Writer, errorCode int, errorMessage string) {
 
 func getContainerFromVars(c *context, vars map[string]string) (string, *cluster.Container, error) {
 	if name, ok := vars["name"]; ok {
-		if container := c.cluster.Container(name); container != nil {
+		if container := c.container(name); container != nil {
 			if !container.Engine.IsHealthy() {
 				return name, container, fmt.Errorf("Container %s running on unhealthy node %s", name, container.Engine.Name)
 			}
@@ -58,6 +69,9 @@ func getContainerFromVars(c *context, vars map[string]string) (string, *cluster.
 
 	if ID, ok := vars["execid"]; ok {
 		for _, container := range c.cluster.Containers() {
+			if !c.ownsContainer(container) {
+				continue
+			}
 			for _, execID := range container.Info.ExecIDs {
 				if ID == execID {
 					return "", container, nil
@@ -428,3 +442,27 @@ func normalizeEvent(receivedEvent *cluster.Event) ([]byte, error) {
 
 	return data, nil
 }
+
+// exactNameFilters returns the container names targeted by the name filters
+// when all of them are anchored literals (e.g. `^/web$`), which is what
+// clients looking up a single container send. Name filters are regular
+// expressions otherwise and can't be resolved from an index, in which case
+// nil is returned.
+func exactNameFilters(filters dockerfilters.Args) []string {
+	values := filters.Get("name")
+	names := make([]string, 0, len(values))
+	for _, value := range values {
+		if !strings.HasPrefix(value, "^") || !strings.HasSuffix(value, "$") {
+			return nil
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(value, "^"), "$")
+		if name == "" || regexp.QuoteMeta(name) != name {
+			return nil
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return names
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"net/url"
 	"testing"
+
+	dockerfilters "github.com/docker/docker/api/types/filters"
 )
 
 func TestBoolValue(t *testing.T) {
@@ -89,3 +91,27 @@ func TestMatchImageOSError(t *testing.T) {
 		}
 	}
 }
+
+func TestExactNameFilters(t *testing.T) {
+	cases := []struct {
+		values   []string
+		expected int
+	}{
+		{nil, 0},
+		{[]string{"^web$"}, 1},
+		{[]string{"^web$", "^db$"}, 2},
+		{[]string{"^web$", "cache"}, 0},
+		{[]string{"^web.1$"}, 0},
+		{[]string{"^$"}, 0},
+	}
+
+	for _, c := range cases {
+		args := dockerfilters.NewArgs()
+		for _, value := range c.values {
+			args.Add("name", value)
+		}
+		if names := exactNameFilters(args); len(names) != c.expected {
+			t.Fatalf("Values: %v, expected: %d names, actual: %v", c.values, c.expected, names)
+		}
+	}
+}
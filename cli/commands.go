@@ -22,13 +22,14 @@ var (
 			ShortName: "m",
 			Usage:     "Manage a docker cluster",
 			Flags: []cli.Flag{
-				flStrategy, flFilter,
+				flStrategy, flFilter, flPool, flSchedulerExtender,
 				flHosts,
 				flLeaderElection, flLeaderTTL, flManageAdvertise,
 				flTLS, flTLSCaCert, flTLSCert, flTLSKey, flTLSVerify,
 				flRefreshIntervalMin, flRefreshIntervalMax, flFailureRetry, flRefreshRetry,
 				flHeartBeat,
-				flEnableCors,
+				flEnableCors, flNamespaces, flAuthentication, flAuthorization,
+				flAuditLog, flAuditLogMaxSize, flAuditLogMaxBackups, flRateLimit,
 				flCluster, flDiscoveryOpt, flClusterOpt, flRefreshOnNodeFilter, flContainerNameRefreshFilter},
 			Action: manage,
 		},
@@ -117,7 +117,7 @@ var (
 	}
 
 	// hack for go vet
-	flFilterValue = cli.StringSlice(filter.List())
+	flFilterValue = cli.StringSlice(filter.Defaults())
 	// DefaultFilterNumber is exported
 	DefaultFilterNumber = len(flFilterValue)
 
@@ -132,6 +132,47 @@ var (
 		Usage: "cluster driver to use [swarm]",
 		Value: "swarm",
 	}
+	flPool = cli.StringSliceFlag{
+		Name:  "pool",
+		Usage: "node pool definition (ex. name=batch,label=pool=batch,strategy=binpack,constraint=storage==ssd)",
+		Value: &cli.StringSlice{},
+	}
+	flSchedulerExtender = cli.StringSliceFlag{
+		Name:  "scheduler-extender",
+		Usage: "scheduler extender definition (ex. url=http://localhost:8080/filter,timeout=2s,failopen=true)",
+		Value: &cli.StringSlice{},
+	}
+	flNamespaces = cli.StringFlag{
+		Name:  "namespaces",
+		Usage: "JSON file mapping client identities to the namespace they are confined to",
+	}
+	flAuthentication = cli.StringFlag{
+		Name:  "authentication",
+		Usage: "JSON file of API keys and token secret authenticating clients with the Authorization header",
+	}
+	flAuthorization = cli.StringFlag{
+		Name:  "authorization",
+		Usage: "JSON file of rules authorizing the API routes based on the client identity",
+	}
+	flAuditLog = cli.StringFlag{
+		Name:  "audit-log",
+		Usage: "file or syslog address recording the API requests changing the cluster",
+	}
+	flAuditLogMaxSize = cli.IntFlag{
+		Name:  "audit-log-max-size",
+		Value: 100,
+		Usage: "size in megabytes at which the audit log file is rotated",
+	}
+	flAuditLogMaxBackups = cli.IntFlag{
+		Name:  "audit-log-max-backups",
+		Value: 5,
+		Usage: "number of rotated audit log files to keep",
+	}
+	flRateLimit = cli.StringSliceFlag{
+		Name:  "rate-limit",
+		Usage: "per client limit of a class of API routes (ex. class=read,rate=20,burst=40 or class=stream,concurrent=5)",
+		Value: &cli.StringSlice{},
+	}
 	flClusterOpt = cli.StringSliceFlag{
 		Name:  "cluster-opt",
 		Usage: "cluster driver options",
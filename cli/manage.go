@@ -131,6 +131,79 @@ func getDiscoveryOpt(c *cli.Context) map[string]string {
 	return options
 }
 
+// loadNamespaces loads the namespaces of the clients, if configured.
+func loadNamespaces(c *cli.Context) *api.Namespaces {
+	path := c.String("namespaces")
+	if path == "" {
+		return nil
+	}
+	namespaces, err := api.LoadNamespaces(path)
+	if err != nil {
+		log.Fatalf("unable to load namespaces from %s: %v", path, err)
+	}
+	return namespaces
+}
+
+// loadAuthentication loads the API keys and token secret of the API, if
+// configured.
+func loadAuthentication(c *cli.Context) *api.Authentication {
+	path := c.String("authentication")
+	if path == "" {
+		return nil
+	}
+	authentication, err := api.LoadAuthentication(path)
+	if err != nil {
+		log.Fatalf("unable to load authentication from %s: %v", path, err)
+	}
+	return authentication
+}
+
+// loadAuthorization loads the authorization rules of the API, if configured.
+func loadAuthorization(c *cli.Context) *api.Authorization {
+	path := c.String("authorization")
+	if path == "" {
+		return nil
+	}
+	if !c.Bool("tlsverify") && c.String("authentication") == "" {
+		log.Fatal("--authorization requires --tlsverify or --authentication to identify the clients")
+	}
+	authorization, err := api.LoadAuthorization(path)
+	if err != nil {
+		log.Fatalf("unable to load authorization rules from %s: %v", path, err)
+	}
+	return authorization
+}
+
+// loadAudit opens the audit log of the API, if configured.
+func loadAudit(c *cli.Context) *api.Audit {
+	destination := c.String("audit-log")
+	if destination == "" {
+		return nil
+	}
+	audit, err := api.OpenAudit(destination, int64(c.Int("audit-log-max-size"))*1024*1024, c.Int("audit-log-max-backups"))
+	if err != nil {
+		log.Fatalf("unable to open the audit log %s: %v", destination, err)
+	}
+	return audit
+}
+
+// loadRateLimiter creates the rate limiter of the API, if configured.
+func loadRateLimiter(c *cli.Context) *api.RateLimiter {
+	definitions := c.StringSlice("rate-limit")
+	if len(definitions) == 0 {
+		return nil
+	}
+	rateLimiter := api.NewRateLimiter()
+	for _, definition := range definitions {
+		class, limit, err := api.ParseRateLimit(definition)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rateLimiter.SetLimit(class, limit)
+	}
+	return rateLimiter
+}
+
 func getCandidateAndFollower(discovery discovery.Backend, addr string, leaderTTL time.Duration) (*leadership.Candidate, *leadership.Follower) {
 	kvDiscovery, ok := discovery.(*kvdiscovery.Discovery)
 	if !ok {
@@ -145,7 +218,7 @@ func getCandidateAndFollower(discovery discovery.Backend, addr string, leaderTTL
 }
 
 func setupReplication(c *cli.Context, cluster cluster.Cluster, server *api.Server, candidate *leadership.Candidate, follower *leadership.Follower, addr string, tlsConfig *tls.Config) {
-	primary := api.NewPrimary(cluster, tlsConfig, &statusHandler{cluster, candidate, follower}, c.GlobalBool("debug"), c.Bool("cors"))
+	primary := api.NewPrimary(cluster, tlsConfig, &statusHandler{cluster, candidate, follower}, loadNamespaces(c), loadAuthentication(c), loadAuthorization(c), loadAudit(c), loadRateLimiter(c), c.GlobalBool("debug"), c.Bool("cors"))
 	replica := api.NewReplica(primary, tlsConfig, addr)
 
 	go func() {
@@ -231,6 +304,12 @@ func manage(c *cli.Context) {
 		if err != nil {
 			log.Fatal(err)
 		}
+		// Clients authenticating with an API key or token may not have a
+		// certificate, the requests without a verified certificate nor a
+		// credential are refused by the API.
+		if c.Bool("tlsverify") && c.String("authentication") != "" {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
 	} else {
 		// Otherwise, if neither --tls nor --tlsverify are specified, abort if
 		// the other flags are passed as they will be ignored.
@@ -283,6 +362,22 @@ func manage(c *cli.Context) {
 	}
 
 	sched := scheduler.New(s, fs)
+	for _, definition := range c.StringSlice("pool") {
+		pool, err := scheduler.ParsePool(definition)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := sched.AddPool(pool); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, definition := range c.StringSlice("scheduler-extender") {
+		extender, err := scheduler.ParseExtender(definition)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sched.AddExtender(extender)
+	}
 	var cl cluster.Cluster
 	switch c.String("cluster-driver") {
 	case "swarm":
@@ -327,7 +422,7 @@ func manage(c *cli.Context) {
 
 		setupReplication(c, cl, server, candidate, follower, addr, tlsConfig)
 	} else {
-		server.SetHandler(api.NewPrimary(cl, tlsConfig, &statusHandler{cl, nil, nil}, c.GlobalBool("debug"), c.Bool("cors")))
+		server.SetHandler(api.NewPrimary(cl, tlsConfig, &statusHandler{cl, nil, nil}, loadNamespaces(c), loadAuthentication(c), loadAuthorization(c), loadAudit(c), loadRateLimiter(c), c.GlobalBool("debug"), c.Bool("cors")))
 		cluster.NewWatchdog(cl)
 	}
 	defer cl.CloseWatchQueues()
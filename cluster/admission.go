@@ -0,0 +1,288 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultAdmissionTimeout is the default timeout of admission webhook calls.
+const DefaultAdmissionTimeout = 5 * time.Second
+
+// AdmissionRequest is the body of the requests sent to admission webhooks.
+type AdmissionRequest struct {
+	Name   string
+	Config *ContainerConfig
+}
+
+// AdmissionResponse is the body of the responses of admission webhooks.
+// Mutating webhooks may return a JSON patch (RFC 6902) to apply to the
+// container configuration.
+type AdmissionResponse struct {
+	Allowed bool
+	Reason  string
+	Patch   []PatchOperation
+}
+
+// PatchOperation is a JSON patch operation. The add, remove, replace and test
+// operations are supported.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// AdmissionError is returned when an admission webhook denies a container.
+type AdmissionError struct {
+	Webhook string
+	Reason  string
+}
+
+func (e *AdmissionError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("container denied by admission webhook %s", e.Webhook)
+	}
+	return fmt.Sprintf("container denied by admission webhook %s: %s", e.Webhook, e.Reason)
+}
+
+// Admission calls the admission webhooks validating and mutating container
+// configurations before they get scheduled.
+type Admission struct {
+	Mutating   []string
+	Validating []string
+	FailOpen   bool
+
+	client *http.Client
+}
+
+// NewAdmission creates an Admission calling the given webhooks.
+func NewAdmission(mutating, validating []string, timeout time.Duration, failOpen bool) *Admission {
+	return &Admission{
+		Mutating:   mutating,
+		Validating: validating,
+		FailOpen:   failOpen,
+		client:     &http.Client{Timeout: timeout},
+	}
+}
+
+// Admit runs the mutating webhooks, applying their patches to config, and
+// then the validating webhooks. It returns an *AdmissionError if a webhook
+// denies the container, or returns a patch which can't be applied or leaves
+// config invalid.
+func (a *Admission) Admit(config *ContainerConfig, name string) error {
+	for _, url := range a.Mutating {
+		response, err := a.call(url, config, name)
+		if err != nil {
+			if a.FailOpen {
+				log.WithFields(log.Fields{"url": url, "error": err}).Warn("Ignoring failed admission webhook")
+				continue
+			}
+			return fmt.Errorf("admission webhook %s failed: %v", url, err)
+		}
+		if !response.Allowed {
+			return &AdmissionError{Webhook: url, Reason: response.Reason}
+		}
+		if len(response.Patch) > 0 {
+			if err := patchConfig(config, response.Patch); err != nil {
+				return &AdmissionError{Webhook: url, Reason: fmt.Sprintf("invalid patch: %v", err)}
+			}
+			if err := config.Validate(); err != nil {
+				return &AdmissionError{Webhook: url, Reason: fmt.Sprintf("invalid patched config: %v", err)}
+			}
+		}
+	}
+
+	for _, url := range a.Validating {
+		response, err := a.call(url, config, name)
+		if err != nil {
+			if a.FailOpen {
+				log.WithFields(log.Fields{"url": url, "error": err}).Warn("Ignoring failed admission webhook")
+				continue
+			}
+			return fmt.Errorf("admission webhook %s failed: %v", url, err)
+		}
+		if !response.Allowed {
+			return &AdmissionError{Webhook: url, Reason: response.Reason}
+		}
+	}
+	return nil
+}
+
+func (a *Admission) call(url string, config *ContainerConfig, name string) (*AdmissionResponse, error) {
+	body, err := json.Marshal(AdmissionRequest{Name: name, Config: config})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	response := &AdmissionResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	return response, nil
+}
+
+// patchConfig applies a JSON patch to config.
+func patchConfig(config *ContainerConfig, patch []PatchOperation) error {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+
+	for _, op := range patch {
+		if doc, err = applyPatchOperation(doc, op); err != nil {
+			return err
+		}
+	}
+
+	if raw, err = json.Marshal(doc); err != nil {
+		return err
+	}
+	patched := ContainerConfig{}
+	if err := json.Unmarshal(raw, &patched); err != nil {
+		return err
+	}
+	if patched.Labels == nil {
+		patched.Labels = make(map[string]string)
+	}
+	*config = patched
+	return nil
+}
+
+func applyPatchOperation(doc interface{}, op PatchOperation) (interface{}, error) {
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("missing value for %s %s", op.Op, op.Path)
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, err
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unsupported patch operation %q", op.Op)
+	}
+
+	if op.Path == "" {
+		switch op.Op {
+		case "add", "replace":
+			return value, nil
+		case "test":
+			if !jsonEqual(doc, value) {
+				return nil, fmt.Errorf("test failed for %q", op.Path)
+			}
+			return doc, nil
+		}
+		return nil, fmt.Errorf("can't remove the whole document")
+	}
+	if !strings.HasPrefix(op.Path, "/") {
+		return nil, fmt.Errorf("invalid patch path %q", op.Path)
+	}
+
+	tokens := strings.Split(op.Path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+
+	// Walk to the parent of the target.
+	parent := doc
+	for _, token := range tokens[:len(tokens)-1] {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			child, ok := p[token]
+			if !ok {
+				return nil, fmt.Errorf("path %q not found", op.Path)
+			}
+			parent = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(p) {
+				return nil, fmt.Errorf("path %q not found", op.Path)
+			}
+			parent = p[i]
+		default:
+			return nil, fmt.Errorf("path %q not found", op.Path)
+		}
+	}
+
+	last := tokens[len(tokens)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		current, exists := p[last]
+		switch op.Op {
+		case "add":
+			p[last] = value
+		case "replace", "remove", "test":
+			if !exists {
+				return nil, fmt.Errorf("path %q not found", op.Path)
+			}
+			if op.Op == "replace" {
+				p[last] = value
+			} else if op.Op == "remove" {
+				delete(p, last)
+			} else if !jsonEqual(current, value) {
+				return nil, fmt.Errorf("test failed for %q", op.Path)
+			}
+		}
+		return doc, nil
+	case []interface{}:
+		i := len(p)
+		if last != "-" || op.Op != "add" {
+			var err error
+			if i, err = strconv.Atoi(last); err != nil || i < 0 || i > len(p) || (i == len(p) && op.Op != "add") {
+				return nil, fmt.Errorf("path %q not found", op.Path)
+			}
+		}
+		var updated []interface{}
+		switch op.Op {
+		case "add":
+			updated = append(append(append([]interface{}{}, p[:i]...), value), p[i:]...)
+		case "replace":
+			p[i] = value
+			return doc, nil
+		case "remove":
+			updated = append(append([]interface{}{}, p[:i]...), p[i+1:]...)
+		case "test":
+			if !jsonEqual(p[i], value) {
+				return nil, fmt.Errorf("test failed for %q", op.Path)
+			}
+			return doc, nil
+		}
+		// Slices can't grow in place, replace the array in its parent.
+		return applyPatchOperation(doc, PatchOperation{Op: "replace", Path: parentPath(op.Path), Value: mustMarshal(updated)})
+	}
+	return nil, fmt.Errorf("path %q not found", op.Path)
+}
+
+func parentPath(path string) string {
+	return path[:strings.LastIndex(path, "/")]
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	raw, _ := json.Marshal(v)
+	return raw
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ra, erra := json.Marshal(a)
+	rb, errb := json.Marshal(b)
+	return erra == nil && errb == nil && bytes.Equal(ra, rb)
+}
@@ -0,0 +1,126 @@
+package cluster
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	containertypes "github.com/docker/docker/api/types/container"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmission(t *testing.T) {
+	// Injects a team label and appends an environment variable.
+	mutating := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := AdmissionRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, "web", request.Name)
+		json.NewEncoder(w).Encode(AdmissionResponse{
+			Allowed: true,
+			Patch: []PatchOperation{
+				{Op: "add", Path: "/Labels/team", Value: json.RawMessage(`"core"`)},
+				{Op: "add", Path: "/Env/-", Value: json.RawMessage(`"INJECTED=1"`)},
+			},
+		})
+	}))
+	defer mutating.Close()
+
+	// Denies privileged containers and requires a team label.
+	validating := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := AdmissionRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		response := AdmissionResponse{Allowed: true}
+		if request.Config.HostConfig.Privileged {
+			response = AdmissionResponse{Reason: "privileged containers are not allowed"}
+		} else if request.Config.Labels["team"] == "" {
+			response = AdmissionResponse{Reason: "missing team label"}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer validating.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	newConfig := func(privileged bool) *ContainerConfig {
+		return BuildContainerConfig(containertypes.Config{Env: []string{"FOO=bar"}}, containertypes.HostConfig{Privileged: privileged}, networktypes.NetworkingConfig{})
+	}
+
+	// Validating webhooks only.
+	admission := NewAdmission(nil, []string{validating.URL}, time.Second, false)
+	err := admission.Admit(newConfig(false), "web")
+	assert.IsType(t, &AdmissionError{}, err)
+	assert.Contains(t, err.Error(), "missing team label")
+
+	// Mutating webhooks run first.
+	admission = NewAdmission([]string{mutating.URL}, []string{validating.URL}, time.Second, false)
+	config := newConfig(false)
+	assert.NoError(t, admission.Admit(config, "web"))
+	assert.Equal(t, "core", config.Labels["team"])
+	assert.Equal(t, []string{"FOO=bar", "INJECTED=1"}, config.Env)
+
+	err = admission.Admit(newConfig(true), "web")
+	assert.IsType(t, &AdmissionError{}, err)
+	assert.Contains(t, err.Error(), "privileged containers are not allowed")
+
+	// Patches leaving the config invalid deny the container.
+	invalid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(AdmissionResponse{
+			Allowed: true,
+			Patch: []PatchOperation{
+				{Op: "add", Path: "/HostConfig/CpusetCpus", Value: json.RawMessage(`"invalid"`)},
+			},
+		})
+	}))
+	defer invalid.Close()
+
+	admission = NewAdmission([]string{invalid.URL}, nil, time.Second, false)
+	err = admission.Admit(newConfig(false), "web")
+	assert.IsType(t, &AdmissionError{}, err)
+	assert.Contains(t, err.Error(), invalid.URL)
+
+	// Failing webhooks deny containers unless failing open.
+	admission = NewAdmission(nil, []string{failing.URL}, time.Second, false)
+	err = admission.Admit(newConfig(false), "web")
+	assert.Error(t, err)
+	_, denied := err.(*AdmissionError)
+	assert.False(t, denied)
+
+	admission = NewAdmission(nil, []string{failing.URL}, time.Second, true)
+	assert.NoError(t, admission.Admit(newConfig(false), "web"))
+}
+
+func TestPatchConfig(t *testing.T) {
+	config := BuildContainerConfig(containertypes.Config{
+		Image: "nginx",
+		Env:   []string{"A=1", "B=2"},
+	}, containertypes.HostConfig{}, networktypes.NetworkingConfig{})
+
+	assert.NoError(t, patchConfig(config, []PatchOperation{
+		{Op: "test", Path: "/Image", Value: json.RawMessage(`"nginx"`)},
+		{Op: "replace", Path: "/Image", Value: json.RawMessage(`"registry.local/nginx"`)},
+		{Op: "remove", Path: "/Env/0"},
+		{Op: "add", Path: "/Env/0", Value: json.RawMessage(`"C=3"`)},
+		{Op: "add", Path: "/Labels/com.example~1owner", Value: json.RawMessage(`"me"`)},
+		{Op: "add", Path: "/HostConfig/ReadonlyRootfs", Value: json.RawMessage(`true`)},
+	}))
+	assert.Equal(t, "registry.local/nginx", config.Image)
+	assert.Equal(t, []string{"C=3", "B=2"}, config.Env)
+	assert.Equal(t, "me", config.Labels["com.example/owner"])
+	assert.True(t, config.HostConfig.ReadonlyRootfs)
+
+	for _, op := range []PatchOperation{
+		{Op: "test", Path: "/Image", Value: json.RawMessage(`"redis"`)},
+		{Op: "remove", Path: "/Missing"},
+		{Op: "replace", Path: "/Env/5", Value: json.RawMessage(`"D=4"`)},
+		{Op: "move", Path: "/Image"},
+		{Op: "add", Path: "Image", Value: json.RawMessage(`"redis"`)},
+	} {
+		assert.Error(t, patchConfig(config, []PatchOperation{op}), op.Op+" "+op.Path)
+	}
+}
@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/docker/libkv/store"
+)
+
+// Backend persists the state of the manager, such as the registry
+// credentials or the tenant quotas.
+type Backend interface {
+	// Load returns the data saved, or nil if nothing was saved yet.
+	Load() ([]byte, error)
+	Save(data []byte) error
+}
+
+type fileBackend struct {
+	path string
+}
+
+// NewFileBackend creates a backend saving data in a file.
+func NewFileBackend(path string) Backend {
+	return &fileBackend{path: path}
+}
+
+func (b *fileBackend) Load() ([]byte, error) {
+	data, err := ioutil.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (b *fileBackend) Save(data []byte) error {
+	tmp := b.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+type kvBackend struct {
+	store store.Store
+	key   string
+}
+
+// NewKVBackend creates a backend saving data at a key of a key-value store,
+// shared by the replicated managers.
+func NewKVBackend(s store.Store, key string) Backend {
+	return &kvBackend{store: s, key: key}
+}
+
+func (b *kvBackend) Load() ([]byte, error) {
+	pair, err := b.store.Get(b.key)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pair.Value, nil
+}
+
+func (b *kvBackend) Save(data []byte) error {
+	return b.store.Put(b.key, data, nil)
+}
+
+type memoryBackend struct {
+	data []byte
+}
+
+// NewMemoryBackend creates a backend keeping data in memory, lost when the
+// manager stops.
+func NewMemoryBackend() Backend {
+	return &memoryBackend{}
+}
+
+func (b *memoryBackend) Load() ([]byte, error) {
+	return b.data, nil
+}
+
+func (b *memoryBackend) Save(data []byte) error {
+	b.data = data
+	return nil
+}
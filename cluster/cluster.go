@@ -4,6 +4,7 @@ import (
 	"io"
 
 	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/volume"
 )
 
@@ -35,6 +36,12 @@ type Cluster interface {
 	// cluster.Containers().Get(IDOrName).
 	Container(IDOrName string) *Container
 
+	// LookupContainers returns the containers having at least one of the
+	// given names (if any) and all the given labels, expressed as `key` or
+	// `key=value`. Implementations are expected to answer from an index
+	// rather than by scanning every container.
+	LookupContainers(names, labels []string) Containers
+
 	// Networks returns all networks.
 	Networks() Networks
 
@@ -117,4 +124,23 @@ type Cluster interface {
 
 	// RefreshEngines refreshes all engines in the cluster.
 	RefreshEngines() error
+
+	// SetQuota sets the quota of a tenant. A nil quota removes it.
+	SetQuota(tenant string, quota *Quota) error
+
+	// Quotas returns the quotas of the tenants and their usage.
+	Quotas() ([]QuotaUsage, error)
+
+	// CheckContainerUpdate returns an error if updating the resources of a
+	// container would break the policy, exceed the maximum resource limits
+	// or exceed the quota of its tenant.
+	CheckContainerUpdate(container *Container, update containertypes.UpdateConfig) error
+
+	// SetRegistryCredentials stores the credentials of a registry in the
+	// manager. nil credentials remove them.
+	SetRegistryCredentials(registry string, auth *types.AuthConfig) error
+
+	// RegistryCredentials returns the registries having credentials stored
+	// in the manager.
+	RegistryCredentials() ([]string, error)
 }
@@ -4,21 +4,34 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 // SwarmLabelNamespace defines the key prefix in all custom labels
 const SwarmLabelNamespace = "com.docker.swarm"
 
+// defaultCPUPeriod is the CFS period Docker uses when a CPU quota is set
+// without a period.
+const defaultCPUPeriod = 100000
+
 // ContainerConfig is exported
 // TODO store affinities and constraints in their own fields
 type ContainerConfig struct {
 	container.Config
 	HostConfig       container.HostConfig
 	NetworkingConfig network.NetworkingConfig
+
+	// Platforms are the platforms the image is published for, when known.
+	// They are looked up when the container is scheduled and never sent to
+	// the engines.
+	Platforms []v1.Platform `json:"-"`
 }
 
 // OldContainerConfig contains additional fields for backward compatibility
@@ -140,7 +153,7 @@ func BuildContainerConfig(c container.Config, h container.HostConfig, n network.
 		}
 	}
 
-	return &ContainerConfig{c, h, n}
+	return &ContainerConfig{c, h, n, nil}
 }
 
 func (c *ContainerConfig) extractExprs(key string) []string {
@@ -287,5 +300,190 @@ func (c *ContainerConfig) Validate() error {
 		}
 	}
 
+	if _, err := ParseCpuset(c.HostConfig.CpusetCpus); err != nil {
+		return err
+	}
+
+	if _, err := c.GenericResources(); err != nil {
+		return err
+	}
+
+	if _, err := c.AllocatePorts(); err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// ReservedMemory returns the memory the container reserves: its hard limit,
+// or its soft limit (memory reservation) when no hard limit is set.
+func (c *ContainerConfig) ReservedMemory() int64 {
+	if c.HostConfig.Memory > 0 {
+		return c.HostConfig.Memory
+	}
+	return c.HostConfig.MemoryReservation
+}
+
+// ReservedCpus returns the number of CPUs the container reserves. The CPU
+// shares (already converted to a number of CPUs), NanoCPUs, CPU quota and
+// cpuset are all taken into account, the largest one wins.
+func (c *ContainerConfig) ReservedCpus() int64 {
+	cpus := c.HostConfig.CPUShares
+
+	if nanoCpus := c.HostConfig.NanoCPUs; nanoCpus > 0 {
+		if n := (nanoCpus + 1e9 - 1) / 1e9; n > cpus {
+			cpus = n
+		}
+	}
+
+	if quota := c.HostConfig.CPUQuota; quota > 0 {
+		period := c.HostConfig.CPUPeriod
+		if period <= 0 {
+			period = defaultCPUPeriod
+		}
+		if n := (quota + period - 1) / period; n > cpus {
+			cpus = n
+		}
+	}
+
+	if cpuset, err := ParseCpuset(c.HostConfig.CpusetCpus); err == nil {
+		if n := int64(len(cpuset)); n > cpus {
+			cpus = n
+		}
+	}
+
+	return cpus
+}
+
+// CPULimit returns the number of CPUs the container is limited to through
+// NanoCPUs or the CPU quota, or 0 if it isn't limited.
+func (c *ContainerConfig) CPULimit() float64 {
+	if c.HostConfig.NanoCPUs > 0 {
+		return float64(c.HostConfig.NanoCPUs) / 1e9
+	}
+	if quota := c.HostConfig.CPUQuota; quota > 0 {
+		period := c.HostConfig.CPUPeriod
+		if period <= 0 {
+			period = defaultCPUPeriod
+		}
+		return float64(quota) / float64(period)
+	}
+	return 0
+}
+
+// GenericResources returns the countable resources requested by the
+// container (ex. docker run --label 'com.docker.swarm.resources=fpga:1,nvme:2').
+func (c *ContainerConfig) GenericResources() (map[string]int64, error) {
+	resources := make(map[string]int64)
+	value, ok := c.Labels[SwarmLabelNamespace+".resources"]
+	if !ok || value == "" {
+		return resources, nil
+	}
+
+	for _, resource := range strings.Split(value, ",") {
+		parts := strings.SplitN(resource, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid resource request: %s, expected name:count", resource)
+		}
+		count, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || count <= 0 {
+			return nil, fmt.Errorf("invalid resource request: %s, count should be a positive integer", resource)
+		}
+		resources[parts[0]] += count
+	}
+	return resources, nil
+}
+
+// NamedVolumes returns the names of the volumes mounted by the container,
+// either through binds (ex. docker run -v data:/data) or mounts of type
+// volume.
+func (c *ContainerConfig) NamedVolumes() []string {
+	names := []string{}
+	for _, bind := range c.HostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		// Host paths are absolute or relative paths, volume names can't
+		// start with a dot or contain a slash.
+		if name := parts[0]; name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\`) {
+			names = append(names, name)
+		}
+	}
+	for _, m := range c.HostConfig.Mounts {
+		if m.Type == mount.TypeVolume && m.Source != "" {
+			names = append(names, m.Source)
+		}
+	}
+	return names
+}
+
+// AllocatePorts returns the container ports to publish on a host port
+// allocated by the manager (ex. docker run --label 'com.docker.swarm.allocate-ports=80,53/udp').
+func (c *ContainerConfig) AllocatePorts() ([]nat.Port, error) {
+	ports := []nat.Port{}
+	value, ok := c.Labels[SwarmLabelNamespace+".allocate-ports"]
+	if !ok || value == "" {
+		return ports, nil
+	}
+
+	for _, raw := range strings.Split(value, ",") {
+		proto, port := nat.SplitProtoPort(raw)
+		if n, err := nat.ParsePort(port); err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid port to allocate: %s", raw)
+		}
+		p, err := nat.NewPort(proto, port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port to allocate: %s", raw)
+		}
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+// maxCpusetCPU is the highest CPU number accepted in a cpuset, the largest
+// number of CPUs Linux supports (CONFIG_NR_CPUS) minus one.
+const maxCpusetCPU = 8191
+
+// ParseCpuset parses a cpuset such as "0-2,4" into the set of CPUs it
+// contains. An empty cpuset returns an empty set.
+func ParseCpuset(cpuset string) (map[int]bool, error) {
+	cpus := make(map[int]bool)
+	if cpuset == "" {
+		return cpus, nil
+	}
+
+	for _, part := range strings.Split(cpuset, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid cpuset: %s", cpuset)
+		}
+		end := start
+		if len(bounds) == 2 {
+			end, err = strconv.Atoi(bounds[1])
+			if err != nil || end < start {
+				return nil, fmt.Errorf("invalid cpuset: %s", cpuset)
+			}
+		}
+		if end > maxCpusetCPU {
+			return nil, fmt.Errorf("invalid cpuset: %s, CPUs are numbered up to %d", cpuset, maxCpusetCPU)
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			cpus[cpu] = true
+		}
+	}
+	return cpus, nil
+}
+
+// Strategy returns the placement strategy requested by the container, if any
+// (ex. docker run --label com.docker.swarm.strategy=binpack).
+func (c *ContainerConfig) Strategy() string {
+	return c.Labels[SwarmLabelNamespace+".strategy"]
+}
+
+// Pool returns the node pool requested by the container, if any
+// (ex. docker run --label com.docker.swarm.pool=batch).
+func (c *ContainerConfig) Pool() string {
+	return c.Labels[SwarmLabelNamespace+".pool"]
+}
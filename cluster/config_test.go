@@ -4,7 +4,9 @@ import (
 	"testing"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -104,3 +106,100 @@ func TestHaveNodeConstraint(t *testing.T) {
 	config = BuildContainerConfig(container.Config{Env: []string{"constraint:node==node1"}}, container.HostConfig{}, network.NetworkingConfig{})
 	assert.True(t, config.HaveNodeConstraint())
 }
+
+func TestReservedMemory(t *testing.T) {
+	config := BuildContainerConfig(container.Config{}, container.HostConfig{}, network.NetworkingConfig{})
+	assert.Equal(t, config.ReservedMemory(), int64(0))
+
+	config.HostConfig.MemoryReservation = 256
+	assert.Equal(t, config.ReservedMemory(), int64(256))
+
+	config.HostConfig.Memory = 512
+	assert.Equal(t, config.ReservedMemory(), int64(512))
+}
+
+func TestReservedCpus(t *testing.T) {
+	config := BuildContainerConfig(container.Config{}, container.HostConfig{}, network.NetworkingConfig{})
+	assert.Equal(t, config.ReservedCpus(), int64(0))
+
+	config.HostConfig.CPUShares = 1
+	assert.Equal(t, config.ReservedCpus(), int64(1))
+
+	config.HostConfig.NanoCPUs = 1500000000
+	assert.Equal(t, config.ReservedCpus(), int64(2))
+
+	config.HostConfig.CPUQuota = 300000
+	assert.Equal(t, config.ReservedCpus(), int64(3))
+
+	config.HostConfig.CPUPeriod = 50000
+	assert.Equal(t, config.ReservedCpus(), int64(6))
+
+	config = BuildContainerConfig(container.Config{}, container.HostConfig{}, network.NetworkingConfig{})
+	config.HostConfig.CpusetCpus = "0-2,4"
+	assert.Equal(t, config.ReservedCpus(), int64(4))
+}
+
+func TestParseCpuset(t *testing.T) {
+	cpus, err := ParseCpuset("")
+	assert.NoError(t, err)
+	assert.Empty(t, cpus)
+
+	cpus, err = ParseCpuset("0-2,5")
+	assert.NoError(t, err)
+	assert.Equal(t, cpus, map[int]bool{0: true, 1: true, 2: true, 5: true})
+
+	for _, cpuset := range []string{"a", "2-1", "-1", "1,", "1-a", "0-2000000000", "8192"} {
+		_, err = ParseCpuset(cpuset)
+		assert.Error(t, err, cpuset)
+	}
+}
+
+func TestGenericResources(t *testing.T) {
+	config := BuildContainerConfig(container.Config{}, container.HostConfig{}, network.NetworkingConfig{})
+	resources, err := config.GenericResources()
+	assert.NoError(t, err)
+	assert.Empty(t, resources)
+
+	config.Labels[SwarmLabelNamespace+".resources"] = "fpga:1,nvme:2,fpga:1"
+	resources, err = config.GenericResources()
+	assert.NoError(t, err)
+	assert.Equal(t, resources, map[string]int64{"fpga": 2, "nvme": 2})
+	assert.NoError(t, config.Validate())
+
+	for _, value := range []string{"fpga", ":1", "fpga:0", "fpga:-1", "fpga:a", "fpga:1,"} {
+		config.Labels[SwarmLabelNamespace+".resources"] = value
+		_, err = config.GenericResources()
+		assert.Error(t, err, value)
+		assert.Error(t, config.Validate(), value)
+	}
+}
+
+func TestNamedVolumes(t *testing.T) {
+	config := BuildContainerConfig(container.Config{}, container.HostConfig{
+		Binds: []string{"/host:/data", "./relative:/relative", "data:/data:ro", "/anonymous"},
+		Mounts: []mount.Mount{
+			{Type: mount.TypeVolume, Source: "logs", Target: "/logs"},
+			{Type: mount.TypeVolume, Target: "/anonymous"},
+			{Type: mount.TypeBind, Source: "/host", Target: "/host"},
+		},
+	}, network.NetworkingConfig{})
+	assert.Equal(t, config.NamedVolumes(), []string{"data", "logs"})
+}
+
+func TestAllocatePorts(t *testing.T) {
+	config := BuildContainerConfig(container.Config{}, container.HostConfig{}, network.NetworkingConfig{})
+	ports, err := config.AllocatePorts()
+	assert.NoError(t, err)
+	assert.Empty(t, ports)
+
+	config.Labels[SwarmLabelNamespace+".allocate-ports"] = "80,53/udp"
+	ports, err = config.AllocatePorts()
+	assert.NoError(t, err)
+	assert.Equal(t, ports, []nat.Port{"80/tcp", "53/udp"})
+
+	for _, value := range []string{"foo", "0", "80-81", "70000", "80,"} {
+		config.Labels[SwarmLabelNamespace+".allocate-ports"] = value
+		_, err = config.AllocatePorts()
+		assert.Error(t, err, value)
+	}
+}
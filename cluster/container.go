@@ -98,6 +98,11 @@ func (c *Container) Refresh() (*Container, error) {
 	return c.Engine.refreshContainer(c.ID, true)
 }
 
+// isStopped returns true if the container is known not to be running.
+func (c *Container) isStopped() bool {
+	return c.Info.ContainerJSONBase != nil && c.Info.State != nil && !c.Info.State.Running
+}
+
 // Containers represents a list of containers
 type Containers []*Container
 
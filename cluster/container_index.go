@@ -0,0 +1,432 @@
+package cluster
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/pkg/stringid"
+)
+
+// containerSet is a set of containers keyed by container ID.
+type containerSet map[string]*Container
+
+// indexEntry records the keys a container was indexed under, since the
+// container itself may be modified in place before it gets re-indexed.
+type indexEntry struct {
+	container *Container
+	engine    *Engine
+	swarmID   string
+	names     []string
+	labels    map[string]string
+}
+
+// ContainerIndex indexes the containers of a set of engines by ID, Swarm ID,
+// name, engine/name and label, so that lookups don't need to scan every
+// container of the cluster.
+type ContainerIndex struct {
+	sync.RWMutex
+
+	entries   map[string]*indexEntry
+	bySwarmID map[string]containerSet
+	byName    map[string]containerSet
+	byEngine  map[*Engine]containerSet
+	byLabel   map[string]map[string]containerSet
+
+	// ids and swarmIDs are kept sorted for prefix lookups. They are rebuilt
+	// lazily, on the first lookup following a change.
+	ids      []string
+	swarmIDs []string
+	dirty    bool
+}
+
+// NewContainerIndex creates an empty container index.
+func NewContainerIndex() *ContainerIndex {
+	return &ContainerIndex{
+		entries:   make(map[string]*indexEntry),
+		bySwarmID: make(map[string]containerSet),
+		byName:    make(map[string]containerSet),
+		byEngine:  make(map[*Engine]containerSet),
+		byLabel:   make(map[string]map[string]containerSet),
+	}
+}
+
+// withPrefix returns the strings of the sorted slice ss starting with prefix.
+func withPrefix(ss []string, prefix string) []string {
+	i := sort.SearchStrings(ss, prefix)
+	j := i
+	for j < len(ss) && strings.HasPrefix(ss[j], prefix) {
+		j++
+	}
+	return ss[i:j]
+}
+
+func (s containerSet) add(c *Container) {
+	s[c.ID] = c
+}
+
+func addToSet(m map[string]containerSet, key string, c *Container) {
+	set, ok := m[key]
+	if !ok {
+		set = containerSet{}
+		m[key] = set
+	}
+	set.add(c)
+}
+
+func removeFromSet(m map[string]containerSet, key, ID string) {
+	if set, ok := m[key]; ok {
+		delete(set, ID)
+		if len(set) == 0 {
+			delete(m, key)
+		}
+	}
+}
+
+// nameKeys returns the keys a container name is indexed under: the name
+// itself and the name prefixed by the engine ID and the engine name.
+func nameKeys(e *Engine, name string) []string {
+	keys := []string{strings.TrimPrefix(name, "/")}
+	if e != nil {
+		keys = append(keys, e.ID+name, e.Name+name)
+	}
+	return keys
+}
+
+// sameKeys returns true if the entry was indexed under the current keys of c.
+func (entry *indexEntry) sameKeys(c *Container) bool {
+	if entry.engine != c.Engine || len(entry.names) != len(c.Names) {
+		return false
+	}
+	for i, name := range c.Names {
+		if entry.names[i] != name {
+			return false
+		}
+	}
+	if c.Config == nil {
+		return entry.swarmID == "" && len(entry.labels) == 0
+	}
+	if entry.swarmID != c.Config.SwarmID() || len(entry.labels) != len(c.Config.Labels) {
+		return false
+	}
+	for k, v := range c.Config.Labels {
+		if value, ok := entry.labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Add indexes a container, replacing any previous entry with the same ID.
+func (idx *ContainerIndex) Add(c *Container) {
+	idx.Lock()
+	defer idx.Unlock()
+
+	// Engines re-add all their containers on every refresh. Only update the
+	// references when none of the indexed keys changed.
+	if entry, ok := idx.entries[c.ID]; ok && entry.sameKeys(c) {
+		if entry.container != c {
+			entry.container = c
+			idx.replaceLocked(entry, c)
+		}
+		return
+	}
+
+	idx.removeLocked(c.ID)
+
+	entry := &indexEntry{
+		container: c,
+		engine:    c.Engine,
+		names:     append([]string{}, c.Names...),
+		labels:    map[string]string{},
+	}
+	if c.Config != nil {
+		entry.swarmID = c.Config.SwarmID()
+		for k, v := range c.Config.Labels {
+			entry.labels[k] = v
+		}
+	}
+	idx.entries[c.ID] = entry
+	idx.dirty = true
+
+	if entry.swarmID != "" {
+		addToSet(idx.bySwarmID, entry.swarmID, c)
+	}
+	for _, name := range entry.names {
+		for _, key := range nameKeys(entry.engine, name) {
+			addToSet(idx.byName, key, c)
+		}
+	}
+	if entry.engine != nil {
+		set, ok := idx.byEngine[entry.engine]
+		if !ok {
+			set = containerSet{}
+			idx.byEngine[entry.engine] = set
+		}
+		set.add(c)
+	}
+	for k, v := range entry.labels {
+		values, ok := idx.byLabel[k]
+		if !ok {
+			values = make(map[string]containerSet)
+			idx.byLabel[k] = values
+		}
+		addToSet(values, v, c)
+	}
+}
+
+// Remove removes the container with the given ID from the index.
+func (idx *ContainerIndex) Remove(ID string) {
+	idx.Lock()
+	defer idx.Unlock()
+
+	idx.removeLocked(ID)
+}
+
+// RemoveEngine removes all the containers of an engine from the index.
+func (idx *ContainerIndex) RemoveEngine(e *Engine) {
+	idx.Lock()
+	defer idx.Unlock()
+
+	for ID := range idx.byEngine[e] {
+		idx.removeLocked(ID)
+	}
+}
+
+func (idx *ContainerIndex) removeLocked(ID string) {
+	entry, ok := idx.entries[ID]
+	if !ok {
+		return
+	}
+	delete(idx.entries, ID)
+	idx.dirty = true
+
+	if entry.swarmID != "" {
+		removeFromSet(idx.bySwarmID, entry.swarmID, ID)
+	}
+	for _, name := range entry.names {
+		for _, key := range nameKeys(entry.engine, name) {
+			removeFromSet(idx.byName, key, ID)
+		}
+	}
+	if set, ok := idx.byEngine[entry.engine]; ok {
+		delete(set, ID)
+		if len(set) == 0 {
+			delete(idx.byEngine, entry.engine)
+		}
+	}
+	for k, v := range entry.labels {
+		if values, ok := idx.byLabel[k]; ok {
+			removeFromSet(values, v, ID)
+			if len(values) == 0 {
+				delete(idx.byLabel, k)
+			}
+		}
+	}
+}
+
+// replaceLocked swaps the container referenced by the sets an unchanged entry
+// belongs to.
+func (idx *ContainerIndex) replaceLocked(entry *indexEntry, c *Container) {
+	if entry.swarmID != "" {
+		idx.bySwarmID[entry.swarmID].add(c)
+	}
+	for _, name := range entry.names {
+		for _, key := range nameKeys(entry.engine, name) {
+			idx.byName[key].add(c)
+		}
+	}
+	if entry.engine != nil {
+		idx.byEngine[entry.engine].add(c)
+	}
+	for k, v := range entry.labels {
+		idx.byLabel[k][v].add(c)
+	}
+}
+
+// sortLocked rebuilds the sorted ID lists if the index changed.
+func (idx *ContainerIndex) sortLocked() {
+	if !idx.dirty {
+		return
+	}
+	idx.ids = make([]string, 0, len(idx.entries))
+	for ID := range idx.entries {
+		idx.ids = append(idx.ids, ID)
+	}
+	sort.Strings(idx.ids)
+	idx.swarmIDs = make([]string, 0, len(idx.bySwarmID))
+	for swarmID := range idx.bySwarmID {
+		idx.swarmIDs = append(idx.swarmIDs, swarmID)
+	}
+	sort.Strings(idx.swarmIDs)
+	idx.dirty = false
+}
+
+// Len returns the number of indexed containers.
+func (idx *ContainerIndex) Len() int {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	return len(idx.entries)
+}
+
+// Get returns a container using its ID or Name. It follows the same
+// resolution rules as Containers.Get.
+func (idx *ContainerIndex) Get(IDOrName string) *Container {
+	// Abort immediately if the name is empty.
+	if len(IDOrName) == 0 {
+		return nil
+	}
+
+	idx.RLock()
+	dirty := idx.dirty
+	idx.RUnlock()
+	if dirty {
+		idx.Lock()
+		idx.sortLocked()
+		idx.Unlock()
+	}
+
+	idx.RLock()
+	defer idx.RUnlock()
+
+	// Match exact or short Container ID.
+	if entry, ok := idx.entries[IDOrName]; ok {
+		return entry.container
+	}
+	if len(IDOrName) == len(stringid.TruncateID(IDOrName)) {
+		for _, ID := range withPrefix(idx.ids, IDOrName) {
+			if entry, ok := idx.entries[ID]; ok && stringid.TruncateID(ID) == IDOrName {
+				return entry.container
+			}
+		}
+	}
+
+	// Match exact Swarm ID.
+	if c := idx.firstOf(idx.bySwarmID[IDOrName]); c != nil {
+		return c
+	}
+	if len(IDOrName) == len(stringid.TruncateID(IDOrName)) {
+		for _, swarmID := range withPrefix(idx.swarmIDs, IDOrName) {
+			if stringid.TruncateID(swarmID) == IDOrName {
+				return idx.firstOf(idx.bySwarmID[swarmID])
+			}
+		}
+	}
+
+	// Match name, /name or engine/name.
+	if candidates := idx.byName[strings.TrimPrefix(IDOrName, "/")]; len(candidates) == 1 {
+		return idx.firstOf(candidates)
+	} else if len(candidates) > 1 {
+		return nil
+	}
+
+	// Match Container ID and Swarm ID prefixes.
+	var (
+		candidate *Container
+		matches   int
+	)
+	for _, ID := range withPrefix(idx.ids, IDOrName) {
+		if entry, ok := idx.entries[ID]; ok {
+			candidate = entry.container
+			matches++
+		}
+	}
+	for _, swarmID := range withPrefix(idx.swarmIDs, IDOrName) {
+		for _, c := range idx.bySwarmID[swarmID] {
+			candidate = c
+			matches++
+		}
+	}
+	if matches == 1 {
+		return candidate
+	}
+
+	return nil
+}
+
+// firstOf returns the container of a set with the lowest ID, so that lookups
+// are deterministic when several containers share a key.
+func (idx *ContainerIndex) firstOf(set containerSet) *Container {
+	var first *Container
+	for _, c := range set {
+		if first == nil || c.ID < first.ID {
+			first = c
+		}
+	}
+	return first
+}
+
+// HasName returns true if a container is named name. Unlike Lookup, the
+// engine/name keys don't count.
+func (idx *ContainerIndex) HasName(name string) bool {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	for ID := range idx.byName[strings.TrimPrefix(name, "/")] {
+		for _, cname := range idx.entries[ID].names {
+			if cname == name || cname == "/"+name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Lookup returns the containers having at least one of the given names (if
+// any) and all the given labels. Labels are expressed as `key` or
+// `key=value`.
+func (idx *ContainerIndex) Lookup(names, labels []string) Containers {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	// Start from the smallest candidate set to keep intersections cheap.
+	var sets []containerSet
+	if len(names) > 0 {
+		set := containerSet{}
+		for _, name := range names {
+			for ID, c := range idx.byName[strings.TrimPrefix(name, "/")] {
+				set[ID] = c
+			}
+		}
+		sets = append(sets, set)
+	}
+	for _, label := range labels {
+		kv := strings.SplitN(label, "=", 2)
+		values := idx.byLabel[kv[0]]
+		if len(kv) == 2 {
+			sets = append(sets, values[kv[1]])
+			continue
+		}
+		set := containerSet{}
+		for _, s := range values {
+			for ID, c := range s {
+				set[ID] = c
+			}
+		}
+		sets = append(sets, set)
+	}
+
+	out := Containers{}
+	if len(sets) == 0 {
+		for _, entry := range idx.entries {
+			out = append(out, entry.container)
+		}
+		return out
+	}
+
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+	for ID, c := range sets[0] {
+		found := true
+		for _, set := range sets[1:] {
+			if _, ok := set[ID]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerIndexGet(t *testing.T) {
+	engine := &Engine{ID: "test-engine", Name: "test-node"}
+	idx := NewContainerIndex()
+	idx.Add(&Container{
+		Container: types.Container{
+			ID:    "container1-id",
+			Names: []string{"/container1-name1", "/container1-name2"},
+		},
+		Engine: engine,
+		Config: BuildContainerConfig(containertypes.Config{
+			Labels: map[string]string{
+				"com.docker.swarm.id": "swarm1-id",
+			},
+		}, containertypes.HostConfig{}, networktypes.NetworkingConfig{}),
+	})
+	idx.Add(&Container{
+		Container: types.Container{
+			ID:    "container2-id",
+			Names: []string{"/con"},
+		},
+		Engine: engine,
+		Config: BuildContainerConfig(containertypes.Config{
+			Labels: map[string]string{
+				"com.docker.swarm.id": "swarm2-id",
+			},
+		}, containertypes.HostConfig{}, networktypes.NetworkingConfig{}),
+	})
+
+	// Invalid lookup
+	assert.Nil(t, idx.Get("invalid-id"))
+	assert.Nil(t, idx.Get(""))
+	// Container ID lookup.
+	assert.NotNil(t, idx.Get("container1-id"))
+	// Container short ID lookup.
+	assert.NotNil(t, idx.Get("container1-i"))
+	// Container ID prefix lookup.
+	assert.NotNil(t, idx.Get("container1-"))
+	assert.Nil(t, idx.Get("container"))
+	// Container name lookup.
+	assert.NotNil(t, idx.Get("container1-name1"))
+	assert.NotNil(t, idx.Get("/container1-name2"))
+	// Container engine/name matching.
+	assert.NotNil(t, idx.Get("test-engine/container1-name1"))
+	assert.NotNil(t, idx.Get("test-node/container1-name2"))
+	// Swarm ID lookup.
+	assert.NotNil(t, idx.Get("swarm1-id"))
+	// Swarm ID prefix lookup.
+	assert.NotNil(t, idx.Get("swarm1-"))
+	assert.Nil(t, idx.Get("swarm"))
+	// Get name before ID prefix
+	cc := idx.Get("con")
+	assert.NotNil(t, cc)
+	assert.Equal(t, cc.ID, "container2-id")
+
+	// Removed containers can't be found anymore.
+	idx.Remove("container2-id")
+	assert.Nil(t, idx.Get("swarm2-id"))
+	assert.Equal(t, "container1-id", idx.Get("con").ID)
+	assert.NotNil(t, idx.Get("container"))
+	assert.Equal(t, 1, idx.Len())
+
+	idx.RemoveEngine(engine)
+	assert.Equal(t, 0, idx.Len())
+}
+
+func TestContainerIndexLookup(t *testing.T) {
+	engine := &Engine{ID: "test-engine", Name: "test-node"}
+	idx := NewContainerIndex()
+	add := func(ID, name string, labels map[string]string) *Container {
+		c := &Container{
+			Container: types.Container{ID: ID, Names: []string{"/" + name}},
+			Engine:    engine,
+			Config:    BuildContainerConfig(containertypes.Config{Labels: labels}, containertypes.HostConfig{}, networktypes.NetworkingConfig{}),
+		}
+		idx.Add(c)
+		return c
+	}
+	add("c1", "web", map[string]string{"project": "foo", "tier": "front"})
+	add("c2", "db", map[string]string{"project": "foo", "tier": "back"})
+	c3 := add("c3", "cache", map[string]string{"project": "bar"})
+
+	assert.Len(t, idx.Lookup(nil, nil), 3)
+	assert.Len(t, idx.Lookup(nil, []string{"project"}), 3)
+	assert.Len(t, idx.Lookup(nil, []string{"project=foo"}), 2)
+	assert.Len(t, idx.Lookup(nil, []string{"project=foo", "tier=back"}), 1)
+	assert.Len(t, idx.Lookup(nil, []string{"tier"}), 2)
+	assert.Len(t, idx.Lookup(nil, []string{"project=baz"}), 0)
+	assert.Len(t, idx.Lookup([]string{"web", "cache"}, nil), 2)
+	assert.Len(t, idx.Lookup([]string{"web", "cache"}, []string{"project=foo"}), 1)
+
+	// Re-adding a container with different labels re-indexes it.
+	c3.Config.Labels["project"] = "foo"
+	idx.Add(c3)
+	assert.Len(t, idx.Lookup(nil, []string{"project=foo"}), 3)
+	assert.Len(t, idx.Lookup(nil, []string{"project=bar"}), 0)
+
+	// Renames are picked up as well.
+	c3.Names = []string{"/redis"}
+	idx.Add(c3)
+	assert.Len(t, idx.Lookup([]string{"cache"}, nil), 0)
+	assert.Len(t, idx.Lookup([]string{"redis"}, nil), 1)
+
+	// Only plain names are taken, not the engine/name keys.
+	assert.True(t, idx.HasName("redis"))
+	assert.True(t, idx.HasName("/redis"))
+	assert.False(t, idx.HasName("cache"))
+	assert.Len(t, idx.Lookup([]string{"test-node/redis"}, nil), 1)
+	assert.False(t, idx.HasName("test-node/redis"))
+	assert.False(t, idx.HasName("test-engine/redis"))
+}
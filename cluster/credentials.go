@@ -0,0 +1,186 @@
+package cluster
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+)
+
+// credentialKeySize is the size of the AES-256 key encrypting credentials.
+const credentialKeySize = 32
+
+// LoadCredentialKey reads the key encrypting the credentials from a file,
+// generating it if the file doesn't exist and generate is true. Managers
+// sharing credentials must not generate their key, or each would encrypt the
+// credentials with a different key.
+func LoadCredentialKey(path string, generate bool) ([]byte, error) {
+	key, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && generate {
+		key = make([]byte, credentialKeySize)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, err
+		}
+		return key, ioutil.WriteFile(path, key, 0600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != credentialKeySize {
+		return nil, fmt.Errorf("invalid credential key %s: expected %d bytes, got %d", path, credentialKeySize, len(key))
+	}
+	return key, nil
+}
+
+// CredentialStore holds registry credentials keyed by registry host,
+// encrypted at rest with AES-GCM. The credentials are read from the backend
+// on every lookup, so that all the managers sharing a backend see the same
+// credentials.
+type CredentialStore struct {
+	mu      sync.Mutex
+	backend Backend
+	aead    cipher.AEAD
+}
+
+// NewCredentialStore creates a credential store encrypting the credentials
+// saved in backend with key.
+func NewCredentialStore(backend Backend, key []byte) (*CredentialStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s := &CredentialStore{backend: backend, aead: aead}
+	// Fail early with a wrong key.
+	if _, err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns the credentials of a registry, or nil if there are none.
+func (s *CredentialStore) Get(registry string) (*types.AuthConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	credentials, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	auth, ok := credentials[NormalizeRegistry(registry)]
+	if !ok {
+		return nil, nil
+	}
+	return &auth, nil
+}
+
+// Set sets the credentials of a registry. nil removes them.
+func (s *CredentialStore) Set(registry string, auth *types.AuthConfig) error {
+	registry = NormalizeRegistry(registry)
+	if registry == "" {
+		return errors.New("registry is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	credentials, err := s.load()
+	if err != nil {
+		return err
+	}
+	if auth == nil {
+		delete(credentials, registry)
+	} else {
+		credentials[registry] = *auth
+	}
+	return s.save(credentials)
+}
+
+// Registries returns the sorted registries having credentials.
+func (s *CredentialStore) Registries() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	credentials, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	registries := []string{}
+	for registry := range credentials {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	return registries, nil
+}
+
+// Lookup returns authConfig if it holds client credentials, or else the
+// credentials of the registry of image, if any.
+func (s *CredentialStore) Lookup(image string, authConfig *types.AuthConfig) (*types.AuthConfig, error) {
+	if authConfig != nil && *authConfig != (types.AuthConfig{}) {
+		return authConfig, nil
+	}
+	auth, err := s.Get(imageRegistry(image))
+	if err != nil || auth == nil {
+		return authConfig, err
+	}
+	return auth, nil
+}
+
+func (s *CredentialStore) load() (map[string]types.AuthConfig, error) {
+	credentials := make(map[string]types.AuthConfig)
+	data, err := s.backend.Load()
+	if err != nil || data == nil {
+		return credentials, err
+	}
+	nonceSize := s.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("invalid credentials: data too short")
+	}
+	plaintext, err := s.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt credentials, is the key right? %v", err)
+	}
+	if err := json.Unmarshal(plaintext, &credentials); err != nil {
+		return nil, fmt.Errorf("invalid credentials: %v", err)
+	}
+	return credentials, nil
+}
+
+func (s *CredentialStore) save(credentials map[string]types.AuthConfig) error {
+	plaintext, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	return s.backend.Save(s.aead.Seal(nonce, nonce, plaintext, nil))
+}
+
+// NormalizeRegistry returns the host of a registry address, docker.io for
+// the addresses of the Docker Hub.
+func NormalizeRegistry(registry string) string {
+	registry = strings.ToLower(registry)
+	if i := strings.Index(registry, "://"); i >= 0 {
+		registry = registry[i+3:]
+	}
+	if i := strings.Index(registry, "/"); i >= 0 {
+		registry = registry[:i]
+	}
+	switch registry {
+	case "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	}
+	return registry
+}
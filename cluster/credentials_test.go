@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-credentials")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials")
+
+	// The key is generated once, when allowed.
+	_, err = LoadCredentialKey(filepath.Join(dir, "key"), false)
+	assert.True(t, os.IsNotExist(err))
+	key, err := LoadCredentialKey(filepath.Join(dir, "key"), true)
+	assert.NoError(t, err)
+	assert.Len(t, key, credentialKeySize)
+	again, err := LoadCredentialKey(filepath.Join(dir, "key"), false)
+	assert.NoError(t, err)
+	assert.Equal(t, key, again)
+
+	s, err := NewCredentialStore(NewFileBackend(path), key)
+	assert.NoError(t, err)
+	registries, err := s.Registries()
+	assert.NoError(t, err)
+	assert.Empty(t, registries)
+
+	private := &types.AuthConfig{Username: "deploy", Password: "s3cret"}
+	hub := &types.AuthConfig{Username: "hub", Password: "hub-s3cret"}
+	assert.NoError(t, s.Set("registry.example.com:5000", private))
+	assert.NoError(t, s.Set("https://index.docker.io/v1/", hub))
+	assert.Error(t, s.Set("", private))
+
+	registries, err = s.Registries()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"docker.io", "registry.example.com:5000"}, registries)
+
+	// The credentials are encrypted at rest.
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.False(t, bytes.Contains(data, []byte("s3cret")))
+
+	// Stored credentials are used when clients send none.
+	auth, err := s.Lookup("registry.example.com:5000/team/app:1.0", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, private, auth)
+	auth, err = s.Lookup("busybox", &types.AuthConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, hub, auth)
+	client := &types.AuthConfig{Username: "alice", Password: "alice-s3cret"}
+	auth, err = s.Lookup("busybox", client)
+	assert.NoError(t, err)
+	assert.Equal(t, client, auth)
+	auth, err = s.Lookup("quay.io/team/app", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, auth)
+
+	// Another store with the same key and backend sees the credentials.
+	other, err := NewCredentialStore(NewFileBackend(path), key)
+	assert.NoError(t, err)
+	assert.NoError(t, other.Set("docker.io", nil))
+	auth, err = s.Get("index.docker.io")
+	assert.NoError(t, err)
+	assert.Nil(t, auth)
+
+	// A wrong key is refused.
+	_, err = NewCredentialStore(NewFileBackend(path), bytes.Repeat([]byte{1}, credentialKeySize))
+	assert.Error(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "short"), []byte("short"), 0600))
+	_, err = LoadCredentialKey(filepath.Join(dir, "short"), true)
+	assert.Error(t, err)
+}
+
+func TestNormalizeRegistry(t *testing.T) {
+	assert.Equal(t, "docker.io", NormalizeRegistry("https://index.docker.io/v1/"))
+	assert.Equal(t, "docker.io", NormalizeRegistry("registry-1.docker.io"))
+	assert.Equal(t, "registry.example.com:5000", NormalizeRegistry("Registry.Example.com:5000"))
+	assert.Equal(t, "registry.example.com", NormalizeRegistry("http://registry.example.com/v2/"))
+}
@@ -13,6 +13,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -27,6 +28,7 @@ import (
 	networktypes "github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/volume"
 	engineapi "github.com/docker/docker/client"
+	units "github.com/docker/go-units"
 	engineapinop "github.com/docker/swarm/api/nopclient"
 	"github.com/docker/swarm/swarmclient"
 	"github.com/opencontainers/image-spec/specs-go/v1"
@@ -39,6 +41,14 @@ const (
 
 	// Threshold of delta duration between swarm manager and engine's systime
 	thresholdTime = 2 * time.Second
+
+	// Engine labels adjusting the resources available for scheduling.
+	overcommitLabel     = "swarm.overcommit"
+	reservedMemoryLabel = "swarm.reserved.memory"
+	reservedCpusLabel   = "swarm.reserved.cpus"
+	// Engine labels with this prefix declare countable resources, ex.
+	// swarm.resources.fpga=2.
+	resourcesLabelPrefix = "swarm.resources."
 )
 
 type engineState int
@@ -110,6 +120,9 @@ type EngineOpts struct {
 	RefreshMinInterval time.Duration
 	RefreshMaxInterval time.Duration
 	FailureRetry       int
+	// IgnoreStoppedContainers excludes the resources of stopped containers
+	// from the reserved resources.
+	IgnoreStoppedContainers bool
 }
 
 // Engine represents a docker engine
@@ -125,9 +138,16 @@ type Engine struct {
 	Labels  map[string]string
 	Version string
 
+	// Runtimes, SecurityOptions and Plugins describe what the engine
+	// supports, as reported by its info.
+	Runtimes        []string
+	SecurityOptions []string
+	Plugins         types.PluginsInfo
+
 	stopCh          chan struct{}
 	refreshDelayer  *delayer
 	containers      map[string]*Container
+	containerIndex  *ContainerIndex
 	images          []*Image
 	networks        map[string]*Network
 	volumes         map[string]*Volume
@@ -141,8 +161,19 @@ type Engine struct {
 	failureCount    int
 	overcommitRatio int64
 	opts            *EngineOpts
-	eventsMonitor   *EventsMonitor
-	DeltaDuration   time.Duration // swarm's systime - engine's systime
+
+	eventsMonitor *EventsMonitor
+	DeltaDuration time.Duration // swarm's systime - engine's systime
+
+	// defaultOvercommitRatio is the cluster-wide overcommit ratio, which
+	// the swarm.overcommit engine label overrides.
+	defaultOvercommitRatio int64
+	// reservedMemory and reservedCpus are held back for the host and never
+	// used for scheduling.
+	reservedMemory int64
+	reservedCpus   int64
+	// totalResources are the countable resources declared by the engine.
+	totalResources map[string]int64
 }
 
 // NewEngine is exported
@@ -160,6 +191,8 @@ func NewEngine(addr string, overcommitRatio float64, opts *EngineOpts) *Engine {
 		updatedAt:       time.Now(),
 		overcommitRatio: int64(overcommitRatio * 100),
 		opts:            opts,
+
+		defaultOvercommitRatio: int64(overcommitRatio * 100),
 	}
 	return e
 }
@@ -544,6 +577,15 @@ func (e *Engine) updateSpecs() error {
 	e.Cpus = int64(info.NCPU)
 	e.Memory = info.MemTotal
 
+	runtimes := make([]string, 0, len(info.Runtimes))
+	for name := range info.Runtimes {
+		runtimes = append(runtimes, name)
+	}
+	sort.Strings(runtimes)
+	e.Runtimes = runtimes
+	e.SecurityOptions = info.SecurityOptions
+	e.Plugins = info.Plugins
+
 	e.Labels = map[string]string{}
 	if info.Driver != "" {
 		e.Labels["storagedriver"] = info.Driver
@@ -557,6 +599,13 @@ func (e *Engine) updateSpecs() error {
 	if info.OSType != "" {
 		e.Labels["ostype"] = info.OSType
 	}
+	if info.Architecture != "" {
+		arch, variant := normalizeArchitecture(info.Architecture)
+		e.Labels["architecture"] = arch
+		if variant != "" {
+			e.Labels["variant"] = variant
+		}
+	}
 	for _, label := range info.Labels {
 		kv := strings.SplitN(label, "=", 2)
 		if len(kv) != 2 {
@@ -580,9 +629,130 @@ func (e *Engine) updateSpecs() error {
 			e.Labels[kv[0]] = kv[1]
 		}
 	}
+	e.updateResourceLimits()
 	return nil
 }
 
+// PlatformSupported returns true if a node with the given labels can run an
+// image built for platform p. Labels the node doesn't advertise aren't
+// checked. A node runs the variants of its architecture up to its own, an
+// armv7 node runs arm/v6 images for instance.
+func PlatformSupported(labels map[string]string, p v1.Platform) bool {
+	if ostype, ok := labels["ostype"]; ok && p.OS != "" && ostype != p.OS {
+		return false
+	}
+	if arch, ok := labels["architecture"]; ok && p.Architecture != "" && arch != p.Architecture {
+		return false
+	}
+	if variant, ok := labels["variant"]; ok && p.Variant != "" && !variantSupported(variant, p.Variant) {
+		return false
+	}
+	return true
+}
+
+// PlatformString returns the os/architecture/variant representation of a
+// platform, such as linux/arm/v7.
+func PlatformString(p v1.Platform) string {
+	parts := []string{p.OS}
+	if p.Architecture != "" {
+		parts = append(parts, p.Architecture)
+		if p.Variant != "" {
+			parts = append(parts, p.Variant)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// variantSupported returns true if a node of variant runs images of
+// variant image, such as v7 and v6.
+func variantSupported(node, image string) bool {
+	if node == image {
+		return true
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(node, "v"))
+	if err != nil {
+		return false
+	}
+	i, err := strconv.Atoi(strings.TrimPrefix(image, "v"))
+	if err != nil {
+		return false
+	}
+	return i <= n
+}
+
+// normalizeArchitecture converts the machine hardware name reported by an
+// engine (ex. x86_64, aarch64) into the architecture and variant used by
+// image manifests (ex. amd64, arm64/v8).
+func normalizeArchitecture(machine string) (string, string) {
+	switch machine {
+	case "x86_64", "amd64":
+		return "amd64", ""
+	case "i386", "i486", "i586", "i686", "386":
+		return "386", ""
+	case "aarch64", "arm64":
+		return "arm64", "v8"
+	case "armv8l":
+		return "arm", "v8"
+	case "armv7l", "armhf":
+		return "arm", "v7"
+	case "armv6l", "armel":
+		return "arm", "v6"
+	case "armv5tel", "armv5l":
+		return "arm", "v5"
+	default:
+		return machine, ""
+	}
+}
+
+// updateResourceLimits applies the overcommit ratio and the reserved
+// resources declared through the engine labels. Invalid values are logged and
+// ignored. The engine lock must be held.
+func (e *Engine) updateResourceLimits() {
+	e.overcommitRatio = e.defaultOvercommitRatio
+	e.reservedMemory = 0
+	e.reservedCpus = 0
+	e.totalResources = make(map[string]int64)
+
+	if value, ok := e.Labels[overcommitLabel]; ok {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil || ratio <= -1 {
+			log.Warnf("Engine (ID: %s, Addr: %s) has an invalid %s label (%s), it should be a number larger than -1.", e.ID, e.Addr, overcommitLabel, value)
+		} else {
+			e.overcommitRatio = int64(ratio * 100)
+		}
+	}
+
+	if value, ok := e.Labels[reservedMemoryLabel]; ok {
+		memory, err := units.RAMInBytes(value)
+		if err != nil || memory < 0 || memory > e.Memory {
+			log.Warnf("Engine (ID: %s, Addr: %s) has an invalid %s label (%s), it should be a size between 0 and the engine memory.", e.ID, e.Addr, reservedMemoryLabel, value)
+		} else {
+			e.reservedMemory = memory
+		}
+	}
+
+	if value, ok := e.Labels[reservedCpusLabel]; ok {
+		cpus, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || cpus < 0 || cpus > e.Cpus {
+			log.Warnf("Engine (ID: %s, Addr: %s) has an invalid %s label (%s), it should be a number of CPUs between 0 and the engine CPUs.", e.ID, e.Addr, reservedCpusLabel, value)
+		} else {
+			e.reservedCpus = cpus
+		}
+	}
+
+	for key, value := range e.Labels {
+		if !strings.HasPrefix(key, resourcesLabelPrefix) || len(key) == len(resourcesLabelPrefix) {
+			continue
+		}
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || count < 0 {
+			log.Warnf("Engine (ID: %s, Addr: %s) has an invalid %s label (%s), it should be a positive integer.", e.ID, e.Addr, key, value)
+			continue
+		}
+		e.totalResources[strings.TrimPrefix(key, resourcesLabelPrefix)] = count
+	}
+}
+
 // RemoveImage deletes an image from the engine.
 func (e *Engine) RemoveImage(name string, force bool) ([]types.ImageDeleteResponseItem, error) {
 	rmOpts := types.ImageRemoveOptions{
@@ -798,11 +968,11 @@ func (e *Engine) RefreshContainers(full bool) error {
 	e.Lock()
 	defer e.Unlock()
 	for _, containerID := range missingContainerIDs {
-		delete(e.containers, containerID)
+		e.deleteContainerLocked(containerID)
 	}
 	// Update e.containers with the freshly obtained list
-	for containerID, container := range merged {
-		e.containers[containerID] = container
+	for _, container := range merged {
+		e.putContainerLocked(container)
 	}
 
 	return nil
@@ -833,16 +1003,19 @@ func (e *Engine) refreshContainer(ID string, full bool) (*Container, error) {
 	if len(containers) == 0 {
 		// The container doesn't exist on the engine, remove it.
 		e.Lock()
-		delete(e.containers, ID)
+		e.deleteContainerLocked(ID)
 		e.Unlock()
 
 		return nil, nil
 	}
 
-	_, err = e.updateContainer(containers[0], e.containers, full)
-	e.RLock()
+	updated, err := e.updateContainer(containers[0], make(map[string]*Container), full)
+	e.Lock()
+	for _, c := range updated {
+		e.putContainerLocked(c)
+	}
 	container := e.containers[containers[0].ID]
-	e.RUnlock()
+	e.Unlock()
 	return container, err
 }
 
@@ -1088,7 +1261,10 @@ func (e *Engine) UsedMemory() int64 {
 	var r int64
 	e.RLock()
 	for _, c := range e.containers {
-		r += c.Config.HostConfig.Memory
+		if e.opts.IgnoreStoppedContainers && c.isStopped() {
+			continue
+		}
+		r += c.Config.ReservedMemory()
 	}
 	e.RUnlock()
 	return r
@@ -1099,20 +1275,76 @@ func (e *Engine) UsedCpus() int64 {
 	var r int64
 	e.RLock()
 	for _, c := range e.containers {
-		r += c.Config.HostConfig.CPUShares
+		if e.opts.IgnoreStoppedContainers && c.isStopped() {
+			continue
+		}
+		r += c.Config.ReservedCpus()
 	}
 	e.RUnlock()
 	return r
 }
 
-// TotalMemory returns the total memory + overcommit
+// UsedResources returns the countable resources reserved by containers.
+func (e *Engine) UsedResources() map[string]int64 {
+	used := make(map[string]int64)
+	e.RLock()
+	for _, c := range e.containers {
+		if e.opts.IgnoreStoppedContainers && c.isStopped() {
+			continue
+		}
+		resources, err := c.Config.GenericResources()
+		if err != nil {
+			continue
+		}
+		for name, count := range resources {
+			used[name] += count
+		}
+	}
+	e.RUnlock()
+	return used
+}
+
+// TotalResources returns the countable resources declared by the engine.
+func (e *Engine) TotalResources() map[string]int64 {
+	e.RLock()
+	defer e.RUnlock()
+
+	total := make(map[string]int64, len(e.totalResources))
+	for name, count := range e.totalResources {
+		total[name] = count
+	}
+	return total
+}
+
+// PinnedCpus returns the number of distinct CPUs containers are pinned to.
+func (e *Engine) PinnedCpus() int64 {
+	pinned := make(map[int]bool)
+	e.RLock()
+	for _, c := range e.containers {
+		cpus, err := ParseCpuset(c.Config.HostConfig.CpusetCpus)
+		if err != nil {
+			continue
+		}
+		for cpu := range cpus {
+			pinned[cpu] = true
+		}
+	}
+	e.RUnlock()
+	return int64(len(pinned))
+}
+
+// TotalMemory returns the total memory, minus the memory reserved for the
+// host, + overcommit
 func (e *Engine) TotalMemory() int64 {
-	return e.Memory + (e.Memory * e.overcommitRatio / 100)
+	memory := e.Memory - e.reservedMemory
+	return memory + (memory * e.overcommitRatio / 100)
 }
 
-// TotalCpus returns the total cpus + overcommit
+// TotalCpus returns the total cpus, minus the cpus reserved for the host, +
+// overcommit
 func (e *Engine) TotalCpus() int64 {
-	return e.Cpus + (e.Cpus * e.overcommitRatio / 100)
+	cpus := e.Cpus - e.reservedCpus
+	return cpus + (cpus * e.overcommitRatio / 100)
 }
 
 // CreateContainer creates a new container
@@ -1181,7 +1413,7 @@ func (e *Engine) RemoveContainer(container *Container, force, volumes bool) erro
 	// will rewrite this.
 	e.Lock()
 	defer e.Unlock()
-	delete(e.containers, container.ID)
+	e.deleteContainerLocked(container.ID)
 
 	return nil
 }
@@ -1493,7 +1725,7 @@ func (e *Engine) AddContainer(container *Container) error {
 	if _, ok := e.containers[container.ID]; ok {
 		return errors.New("container already exists")
 	}
-	e.containers[container.ID] = container
+	e.putContainerLocked(container)
 	return nil
 }
 
@@ -1513,17 +1745,56 @@ func (e *Engine) removeContainer(container *Container) error {
 	if _, ok := e.containers[container.ID]; !ok {
 		return errors.New("container not found")
 	}
-	delete(e.containers, container.ID)
+	e.deleteContainerLocked(container.ID)
 	return nil
 }
 
 // cleanupContainers wipes the internal container state.
 func (e *Engine) cleanupContainers() {
 	e.Lock()
+	if e.containerIndex != nil {
+		e.containerIndex.RemoveEngine(e)
+	}
 	e.containers = make(map[string]*Container)
 	e.Unlock()
 }
 
+// putContainerLocked stores a container in the internal state and keeps the
+// container index up to date. The engine lock must be held.
+func (e *Engine) putContainerLocked(container *Container) {
+	e.containers[container.ID] = container
+	if e.containerIndex != nil {
+		e.containerIndex.Add(container)
+	}
+}
+
+// deleteContainerLocked removes a container from the internal state and the
+// container index. The engine lock must be held.
+func (e *Engine) deleteContainerLocked(ID string) {
+	delete(e.containers, ID)
+	if e.containerIndex != nil {
+		e.containerIndex.Remove(ID)
+	}
+}
+
+// SetContainerIndex makes the engine maintain its containers in the given
+// index. Passing nil detaches the engine from its current index and removes
+// its containers from it.
+func (e *Engine) SetContainerIndex(index *ContainerIndex) {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.containerIndex != nil {
+		e.containerIndex.RemoveEngine(e)
+	}
+	e.containerIndex = index
+	if index != nil {
+		for _, container := range e.containers {
+			index.Add(container)
+		}
+	}
+}
+
 // StartContainer starts a container
 func (e *Engine) StartContainer(container *Container) error {
 	// TODO(nishanttotla): Should ContainerStartOptions be provided?
@@ -1544,7 +1815,9 @@ func (e *Engine) StartContainer(container *Container) error {
 	// during race conditions where a third-party client removes the container
 	// immediately after it's started.
 	if container.Info.HostConfig.AutoRemove && engineapi.IsErrNotFound(err) {
-		delete(e.containers, container.ID)
+		e.Lock()
+		e.deleteContainerLocked(container.ID)
+		e.Unlock()
 		log.Debugf("container %s was not detected shortly after ContainerStart, indicating a daemon-side removal", container.ID)
 		return nil
 	}
@@ -350,7 +350,7 @@ func TestCreateContainer(t *testing.T) {
 			Resources: containertypes.Resources{
 				CPUShares: 1,
 			},
-		}, networktypes.NetworkingConfig{}}
+		}, networktypes.NetworkingConfig{}, nil}
 		state = types.ContainerState{
 			StartedAt:  "2016-06-06T01:41:38.090313266Z",
 			FinishedAt: "0001-01-01T00:00:00Z",
@@ -557,6 +557,76 @@ func TestTotalCpus(t *testing.T) {
 	assert.Equal(t, engine.TotalCpus(), int64(2))
 }
 
+func TestResourceLabels(t *testing.T) {
+	engine := NewEngine("test", 0.05, engOpts)
+	engine.Memory = 4 * 1024 * 1024 * 1024
+	engine.Cpus = 4
+	engine.Labels = map[string]string{
+		"swarm.overcommit":      "0.5",
+		"swarm.reserved.memory": "2g",
+		"swarm.reserved.cpus":   "1",
+	}
+	engine.updateResourceLimits()
+	assert.Equal(t, engine.TotalMemory(), int64(3*1024*1024*1024))
+	assert.Equal(t, engine.TotalCpus(), int64(3+3*50/100))
+
+	// Invalid labels are ignored.
+	engine.Labels = map[string]string{
+		"swarm.overcommit":      "-2",
+		"swarm.reserved.memory": "8g",
+		"swarm.reserved.cpus":   "foo",
+	}
+	engine.updateResourceLimits()
+	assert.Equal(t, engine.TotalMemory(), engine.Memory+engine.Memory*5/100)
+	assert.Equal(t, engine.TotalCpus(), int64(4+4*5/100))
+}
+
+func TestGenericResourceLabels(t *testing.T) {
+	engine := NewEngine("test", 0, engOpts)
+	engine.Labels = map[string]string{
+		"swarm.resources.fpga": "2",
+		"swarm.resources.nvme": "foo",
+		"swarm.resources.":     "1",
+	}
+	engine.updateResourceLimits()
+	assert.Equal(t, engine.TotalResources(), map[string]int64{"fpga": 2})
+
+	config := BuildContainerConfig(containertypes.Config{
+		Labels: map[string]string{"com.docker.swarm.resources": "fpga:1"},
+	}, containertypes.HostConfig{}, networktypes.NetworkingConfig{})
+	engine.containers = map[string]*Container{"c": {Config: config}}
+	assert.Equal(t, engine.UsedResources(), map[string]int64{"fpga": 1})
+}
+
+func TestUsedMemory(t *testing.T) {
+	newContainer := func(hostConfig containertypes.HostConfig, running bool) *Container {
+		return &Container{
+			Config: &ContainerConfig{HostConfig: hostConfig},
+			Info: types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{
+					State:      &types.ContainerState{Running: running},
+					HostConfig: &hostConfig,
+				},
+			},
+		}
+	}
+
+	engine := NewEngine("test", 0, engOpts)
+	engine.containers = map[string]*Container{
+		"hard":    newContainer(containertypes.HostConfig{Resources: containertypes.Resources{Memory: 512, MemoryReservation: 256}}, true),
+		"soft":    newContainer(containertypes.HostConfig{Resources: containertypes.Resources{MemoryReservation: 128}}, true),
+		"stopped": newContainer(containertypes.HostConfig{Resources: containertypes.Resources{Memory: 64, CPUShares: 1}}, false),
+	}
+	assert.Equal(t, engine.UsedMemory(), int64(512+128+64))
+	assert.Equal(t, engine.UsedCpus(), int64(1))
+
+	opts := *engOpts
+	opts.IgnoreStoppedContainers = true
+	engine.opts = &opts
+	assert.Equal(t, engine.UsedMemory(), int64(512+128))
+	assert.Equal(t, engine.UsedCpus(), int64(0))
+}
+
 func TestUsedCpus(t *testing.T) {
 	var (
 		containerNcpu = []int64{1, 2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47}
@@ -833,7 +903,7 @@ func TestRefreshLoop(t *testing.T) {
 			Resources: containertypes.Resources{
 				CPUShares: 1,
 			},
-		}, networktypes.NetworkingConfig{}}
+		}, networktypes.NetworkingConfig{}, nil}
 		state = types.ContainerState{
 			StartedAt:  "2018-05-07T08:33:22.070211457Z",
 			FinishedAt: "0001-01-01T00:00:00Z",
@@ -906,3 +976,18 @@ func TestRefreshLoop(t *testing.T) {
 	time.Sleep(1 * time.Second)
 	assert.Len(t, engine.Containers(), 1)
 }
+
+func TestNormalizeArchitecture(t *testing.T) {
+	for machine, expected := range map[string][2]string{
+		"x86_64":  {"amd64", ""},
+		"aarch64": {"arm64", "v8"},
+		"armv7l":  {"arm", "v7"},
+		"armv6l":  {"arm", "v6"},
+		"i686":    {"386", ""},
+		"s390x":   {"s390x", ""},
+		"ppc64le": {"ppc64le", ""},
+	} {
+		arch, variant := normalizeArchitecture(machine)
+		assert.Equal(t, [2]string{arch, variant}, expected, machine)
+	}
+}
@@ -0,0 +1,149 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	units "github.com/docker/go-units"
+)
+
+// ResourceLimit holds the default and maximum resources of containers.
+// Memory sizes are human readable, such as 512m.
+type ResourceLimit struct {
+	DefaultMemory string
+	DefaultCpus   float64
+	MaxMemory     string
+	MaxCpus       float64
+
+	defaultMemory int64
+	maxMemory     int64
+}
+
+// ResourceScope overrides the limits of the containers with a given label.
+type ResourceScope struct {
+	ResourceLimit
+	Label string
+	Value string
+}
+
+// ResourceLimits holds the global resource limits and the limits of label
+// scopes. The first scope matching a container overrides the global limits
+// it sets.
+type ResourceLimits struct {
+	ResourceLimit
+	Scopes []*ResourceScope
+}
+
+// LoadResourceLimits reads resource limits from a JSON file.
+func LoadResourceLimits(path string) (*ResourceLimits, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseResourceLimits(data)
+}
+
+// ParseResourceLimits parses JSON resource limits.
+func ParseResourceLimits(data []byte) (*ResourceLimits, error) {
+	limits := &ResourceLimits{}
+	if err := json.Unmarshal(data, limits); err != nil {
+		return nil, fmt.Errorf("invalid resource limits: %v", err)
+	}
+	if err := limits.ResourceLimit.parse(); err != nil {
+		return nil, err
+	}
+	for _, scope := range limits.Scopes {
+		if scope.Label == "" {
+			return nil, fmt.Errorf("missing label of resource scope")
+		}
+		if err := scope.ResourceLimit.parse(); err != nil {
+			return nil, fmt.Errorf("%v in scope %s=%s", err, scope.Label, scope.Value)
+		}
+	}
+	return limits, nil
+}
+
+func (l *ResourceLimit) parse() error {
+	var err error
+	if l.defaultMemory, err = parseMemory(l.DefaultMemory); err != nil {
+		return fmt.Errorf("invalid DefaultMemory %q", l.DefaultMemory)
+	}
+	if l.maxMemory, err = parseMemory(l.MaxMemory); err != nil {
+		return fmt.Errorf("invalid MaxMemory %q", l.MaxMemory)
+	}
+	if l.DefaultCpus < 0 || l.MaxCpus < 0 {
+		return fmt.Errorf("CPU limits can't be negative")
+	}
+	if l.maxMemory > 0 && l.defaultMemory > l.maxMemory {
+		return fmt.Errorf("DefaultMemory %s is larger than MaxMemory %s", l.DefaultMemory, l.MaxMemory)
+	}
+	if l.MaxCpus > 0 && l.DefaultCpus > l.MaxCpus {
+		return fmt.Errorf("DefaultCpus %v is larger than MaxCpus %v", l.DefaultCpus, l.MaxCpus)
+	}
+	return nil
+}
+
+func parseMemory(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	memory, err := units.RAMInBytes(size)
+	if err == nil && memory <= 0 {
+		err = fmt.Errorf("invalid size %s", size)
+	}
+	return memory, err
+}
+
+// limitFor returns the limits applying to a container with the given labels.
+func (l *ResourceLimits) limitFor(labels map[string]string) ResourceLimit {
+	limit := l.ResourceLimit
+	for _, scope := range l.Scopes {
+		if value, ok := labels[scope.Label]; !ok || value != scope.Value {
+			continue
+		}
+		if scope.defaultMemory > 0 {
+			limit.DefaultMemory, limit.defaultMemory = scope.DefaultMemory, scope.defaultMemory
+		}
+		if scope.DefaultCpus > 0 {
+			limit.DefaultCpus = scope.DefaultCpus
+		}
+		if scope.maxMemory > 0 {
+			limit.MaxMemory, limit.maxMemory = scope.MaxMemory, scope.maxMemory
+		}
+		if scope.MaxCpus > 0 {
+			limit.MaxCpus = scope.MaxCpus
+		}
+		break
+	}
+	return limit
+}
+
+// ApplyDefaults sets the default memory and CPU limits of a container being
+// created which doesn't set any. The default memory limit is raised to the
+// memory reservation of the container, which the engine requires to be
+// smaller than the limit.
+func (l *ResourceLimits) ApplyDefaults(c *ContainerConfig) {
+	limit := l.limitFor(c.Labels)
+	if c.HostConfig.Memory == 0 && limit.defaultMemory > 0 {
+		c.HostConfig.Memory = limit.defaultMemory
+		if c.HostConfig.MemoryReservation > c.HostConfig.Memory {
+			c.HostConfig.Memory = c.HostConfig.MemoryReservation
+		}
+	}
+	if c.HostConfig.NanoCPUs == 0 && c.HostConfig.CPUQuota == 0 && limit.DefaultCpus > 0 {
+		c.HostConfig.NanoCPUs = int64(limit.DefaultCpus * 1e9)
+	}
+}
+
+// Check returns an error if the container exceeds its maximum limits.
+func (l *ResourceLimits) Check(c *ContainerConfig) error {
+	limit := l.limitFor(c.Labels)
+	if limit.maxMemory > 0 && c.HostConfig.Memory > limit.maxMemory {
+		return fmt.Errorf("memory limit %s exceeds the maximum of %s", units.BytesSize(float64(c.HostConfig.Memory)), limit.MaxMemory)
+	}
+	if cpus := c.CPULimit(); limit.MaxCpus > 0 && cpus > limit.MaxCpus {
+		return fmt.Errorf("CPU limit %v exceeds the maximum of %v", cpus, limit.MaxCpus)
+	}
+	return nil
+}
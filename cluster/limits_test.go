@@ -0,0 +1,74 @@
+package cluster
+
+import (
+	"testing"
+
+	containertypes "github.com/docker/docker/api/types/container"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceLimits(t *testing.T) {
+	limits, err := ParseResourceLimits([]byte(`{
+		"DefaultMemory": "256m", "DefaultCpus": 0.5, "MaxMemory": "1g", "MaxCpus": 2,
+		"Scopes": [
+			{"Label": "team", "Value": "batch", "DefaultMemory": "1g", "MaxMemory": "8g"}
+		]
+	}`))
+	assert.NoError(t, err)
+
+	build := func(labels map[string]string, resources containertypes.Resources) *ContainerConfig {
+		config := BuildContainerConfig(containertypes.Config{Labels: labels}, containertypes.HostConfig{Resources: resources}, networktypes.NetworkingConfig{})
+		limits.ApplyDefaults(config)
+		return config
+	}
+
+	// Defaults apply to the containers without limits.
+	config := build(nil, containertypes.Resources{})
+	assert.Equal(t, int64(256*1024*1024), config.HostConfig.Memory)
+	assert.Equal(t, int64(5e8), config.HostConfig.NanoCPUs)
+	assert.Equal(t, int64(256*1024*1024), config.ReservedMemory())
+	assert.Equal(t, int64(1), config.ReservedCpus())
+	assert.NoError(t, limits.Check(config))
+
+	config = build(nil, containertypes.Resources{Memory: 512 * 1024 * 1024, CPUQuota: 100000})
+	assert.Equal(t, int64(512*1024*1024), config.HostConfig.Memory)
+	assert.Equal(t, int64(0), config.HostConfig.NanoCPUs)
+	assert.NoError(t, limits.Check(config))
+
+	// The default memory limit isn't smaller than the memory reservation.
+	config = build(nil, containertypes.Resources{MemoryReservation: 512 * 1024 * 1024})
+	assert.Equal(t, int64(512*1024*1024), config.HostConfig.Memory)
+	assert.Error(t, limits.Check(build(nil, containertypes.Resources{MemoryReservation: 2 * 1024 * 1024 * 1024})))
+
+	// Maximums are enforced.
+	assert.Error(t, limits.Check(build(nil, containertypes.Resources{Memory: 2 * 1024 * 1024 * 1024})))
+	assert.Error(t, limits.Check(build(nil, containertypes.Resources{NanoCPUs: 4e9})))
+
+	// Scopes override the global limits they set.
+	config = build(map[string]string{"team": "batch"}, containertypes.Resources{})
+	assert.Equal(t, int64(1024*1024*1024), config.HostConfig.Memory)
+	assert.Equal(t, int64(5e8), config.HostConfig.NanoCPUs)
+	assert.NoError(t, limits.Check(build(map[string]string{"team": "batch"}, containertypes.Resources{Memory: 4 * 1024 * 1024 * 1024})))
+	assert.Error(t, limits.Check(build(map[string]string{"team": "batch"}, containertypes.Resources{NanoCPUs: 4e9})))
+
+	// Building a configuration, as done when refreshing containers, doesn't
+	// apply the limits.
+	config = BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{}, networktypes.NetworkingConfig{})
+	assert.Equal(t, int64(0), config.HostConfig.Memory)
+}
+
+func TestParseResourceLimits(t *testing.T) {
+	for _, data := range []string{
+		`{`,
+		`{"DefaultMemory": "lots"}`,
+		`{"MaxCpus": -1}`,
+		`{"DefaultMemory": "2g", "MaxMemory": "1g"}`,
+		`{"DefaultCpus": 4, "MaxCpus": 2}`,
+		`{"Scopes": [{"Value": "batch"}]}`,
+		`{"Scopes": [{"Label": "team", "Value": "batch", "MaxMemory": "0"}]}`,
+	} {
+		_, err := ParseResourceLimits([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
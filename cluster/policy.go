@@ -0,0 +1,230 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types/mount"
+	units "github.com/docker/go-units"
+)
+
+// PolicyRule is a rule of a policy. Every check set on the rule must pass for
+// a container to be accepted.
+type PolicyRule struct {
+	Name string
+
+	DenyPrivileged    bool
+	DenyHostNetwork   bool
+	DenyHostPID       bool
+	DenyBindPaths     []string
+	DenyCapabilities  []string
+	RequireLabels     []string
+	AllowedRegistries []string
+	// MaxMemory is a human readable size, such as 2g.
+	MaxMemory string
+	MaxCpus   float64
+
+	maxMemory int64
+}
+
+// Policy is a set of rules container configurations have to comply with.
+type Policy struct {
+	Rules []*PolicyRule
+}
+
+// PolicyError is returned when a container doesn't comply with a policy.
+type PolicyError struct {
+	Rule   string
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("container denied by policy rule %s: %s", e.Rule, e.Reason)
+}
+
+// ParsePolicy parses a JSON policy.
+func ParsePolicy(data []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid policy: %v", err)
+	}
+	for i, rule := range policy.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if rule.MaxMemory != "" {
+			memory, err := units.RAMInBytes(rule.MaxMemory)
+			if err != nil || memory <= 0 {
+				return nil, fmt.Errorf("invalid MaxMemory %q in policy rule %s", rule.MaxMemory, rule.Name)
+			}
+			rule.maxMemory = memory
+		}
+		if rule.MaxCpus < 0 {
+			return nil, fmt.Errorf("invalid MaxCpus %v in policy rule %s", rule.MaxCpus, rule.Name)
+		}
+	}
+	return policy, nil
+}
+
+// Check returns a *PolicyError naming the first rule the container doesn't
+// comply with, if any.
+func (p *Policy) Check(config *ContainerConfig) error {
+	for _, rule := range p.Rules {
+		if reason := rule.check(config); reason != "" {
+			return &PolicyError{Rule: rule.Name, Reason: reason}
+		}
+	}
+	return nil
+}
+
+func (r *PolicyRule) check(config *ContainerConfig) string {
+	if r.DenyPrivileged && config.HostConfig.Privileged {
+		return "privileged containers are not allowed"
+	}
+	if r.DenyHostNetwork && config.HostConfig.NetworkMode.IsHost() {
+		return "the host network is not allowed"
+	}
+	if r.DenyHostPID && config.HostConfig.PidMode.IsHost() {
+		return "the host PID namespace is not allowed"
+	}
+	for _, source := range bindSources(config) {
+		for _, denied := range r.DenyBindPaths {
+			if withinPath(source, denied) {
+				return fmt.Sprintf("bind mounting %s is not allowed", source)
+			}
+		}
+	}
+	for _, capability := range config.HostConfig.CapAdd {
+		for _, denied := range r.DenyCapabilities {
+			if normalizeCapability(capability) == "ALL" || normalizeCapability(capability) == normalizeCapability(denied) {
+				return fmt.Sprintf("adding capability %s is not allowed", capability)
+			}
+		}
+	}
+	for _, label := range r.RequireLabels {
+		if config.Labels[label] == "" {
+			return fmt.Sprintf("label %s is required", label)
+		}
+	}
+	if len(r.AllowedRegistries) > 0 {
+		registry := imageRegistry(config.Image)
+		allowed := false
+		for _, allowedRegistry := range r.AllowedRegistries {
+			if registry == allowedRegistry {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Sprintf("images from registry %s are not allowed", registry)
+		}
+	}
+	if r.maxMemory > 0 {
+		if memory := config.HostConfig.Memory; memory <= 0 || memory > r.maxMemory {
+			return fmt.Sprintf("the memory limit must be set and at most %s", units.BytesSize(float64(r.maxMemory)))
+		}
+	}
+	if r.MaxCpus > 0 {
+		if cpus := config.CPULimit(); cpus <= 0 || cpus > r.MaxCpus {
+			return fmt.Sprintf("the CPU limit must be set and at most %v", r.MaxCpus)
+		}
+	}
+	return ""
+}
+
+// bindSources returns the host paths bind mounted in the container, including
+// the devices of the volumes of the local driver it mounts, which bind mount
+// host paths when created with the bind option.
+func bindSources(config *ContainerConfig) []string {
+	sources := []string{}
+	for _, bind := range config.HostConfig.Binds {
+		if source := strings.SplitN(bind, ":", 2)[0]; strings.HasPrefix(source, "/") {
+			sources = append(sources, source)
+		}
+	}
+	for _, m := range config.HostConfig.Mounts {
+		switch m.Type {
+		case mount.TypeBind:
+			sources = append(sources, m.Source)
+		case mount.TypeVolume:
+			if m.VolumeOptions == nil || m.VolumeOptions.DriverConfig == nil {
+				continue
+			}
+			driver := m.VolumeOptions.DriverConfig
+			if device := driver.Options["device"]; (driver.Name == "" || driver.Name == "local") && strings.HasPrefix(device, "/") {
+				sources = append(sources, device)
+			}
+		}
+	}
+	return sources
+}
+
+// withinPath returns true if p is dir or a path under dir.
+func withinPath(p, dir string) bool {
+	p, dir = path.Clean(p), path.Clean(dir)
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
+func normalizeCapability(capability string) string {
+	return strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+}
+
+// imageRegistry returns the registry hosting an image, docker.io for the
+// images of the Docker Hub.
+func imageRegistry(image string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ""
+	}
+	return reference.Domain(named)
+}
+
+// PolicyFile is a policy loaded from a file, which can be reloaded while in
+// use.
+type PolicyFile struct {
+	sync.RWMutex
+
+	path   string
+	policy *Policy
+}
+
+// NewPolicyFile loads the policy of a file.
+func NewPolicyFile(path string) (*PolicyFile, error) {
+	f := &PolicyFile{path: path}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload reads the policy file again. The current policy is kept if the file
+// is invalid.
+func (f *PolicyFile) Reload() error {
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return err
+	}
+
+	f.Lock()
+	f.policy = policy
+	f.Unlock()
+	return nil
+}
+
+// Check checks a container configuration against the current policy.
+func (f *PolicyFile) Check(config *ContainerConfig) error {
+	f.RLock()
+	policy := f.policy
+	f.RUnlock()
+
+	return policy.Check(config)
+}
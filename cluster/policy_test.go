@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	containertypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPolicy = `{
+	"Rules": [
+		{"Name": "isolation", "DenyPrivileged": true, "DenyHostNetwork": true, "DenyHostPID": true},
+		{"Name": "host-paths", "DenyBindPaths": ["/var/run/docker.sock", "/etc"]},
+		{"Name": "capabilities", "DenyCapabilities": ["SYS_ADMIN"]},
+		{"Name": "ownership", "RequireLabels": ["team"]},
+		{"Name": "registries", "AllowedRegistries": ["registry.example.com", "docker.io"]},
+		{"Name": "limits", "MaxMemory": "1g", "MaxCpus": 2}
+	]
+}`
+
+func TestPolicy(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	assert.NoError(t, err)
+
+	valid := func() (containertypes.Config, containertypes.HostConfig) {
+		return containertypes.Config{
+			Image:  "registry.example.com/app:1.0",
+			Labels: map[string]string{"team": "core"},
+		}, containertypes.HostConfig{
+			Binds:  []string{"/srv/data:/data", "cache:/cache"},
+			CapAdd: []string{"NET_ADMIN"},
+			Resources: containertypes.Resources{
+				Memory:   512 * 1024 * 1024,
+				NanoCPUs: 1e9,
+			},
+		}
+	}
+
+	config, hostConfig := valid()
+	assert.NoError(t, policy.Check(BuildContainerConfig(config, hostConfig, networktypes.NetworkingConfig{})))
+
+	config, hostConfig = valid()
+	config.Image = "nginx"
+	assert.NoError(t, policy.Check(BuildContainerConfig(config, hostConfig, networktypes.NetworkingConfig{})))
+
+	for _, test := range []struct {
+		rule   string
+		modify func(*containertypes.Config, *containertypes.HostConfig)
+	}{
+		{"isolation", func(c *containertypes.Config, h *containertypes.HostConfig) { h.Privileged = true }},
+		{"isolation", func(c *containertypes.Config, h *containertypes.HostConfig) { h.NetworkMode = "host" }},
+		{"isolation", func(c *containertypes.Config, h *containertypes.HostConfig) { h.PidMode = "host" }},
+		{"host-paths", func(c *containertypes.Config, h *containertypes.HostConfig) { h.Binds = []string{"/etc/ssl:/ssl:ro"} }},
+		{"host-paths", func(c *containertypes.Config, h *containertypes.HostConfig) {
+			h.Mounts = []mount.Mount{{Type: mount.TypeBind, Source: "/var/run/docker.sock"}}
+		}},
+		{"host-paths", func(c *containertypes.Config, h *containertypes.HostConfig) {
+			h.Mounts = []mount.Mount{{Type: mount.TypeVolume, Target: "/data", VolumeOptions: &mount.VolumeOptions{
+				DriverConfig: &mount.Driver{Options: map[string]string{"type": "none", "o": "bind", "device": "/etc"}},
+			}}}
+		}},
+		{"capabilities", func(c *containertypes.Config, h *containertypes.HostConfig) { h.CapAdd = []string{"cap_sys_admin"} }},
+		{"capabilities", func(c *containertypes.Config, h *containertypes.HostConfig) { h.CapAdd = []string{"ALL"} }},
+		{"ownership", func(c *containertypes.Config, h *containertypes.HostConfig) { delete(c.Labels, "team") }},
+		{"registries", func(c *containertypes.Config, h *containertypes.HostConfig) { c.Image = "quay.io/app" }},
+		{"limits", func(c *containertypes.Config, h *containertypes.HostConfig) { h.Memory = 2 * 1024 * 1024 * 1024 }},
+		{"limits", func(c *containertypes.Config, h *containertypes.HostConfig) { h.Memory = 0 }},
+		{"limits", func(c *containertypes.Config, h *containertypes.HostConfig) { h.NanoCPUs = 4e9 }},
+	} {
+		config, hostConfig := valid()
+		test.modify(&config, &hostConfig)
+		err := policy.Check(BuildContainerConfig(config, hostConfig, networktypes.NetworkingConfig{}))
+		if assert.IsType(t, &PolicyError{}, err, test.rule) {
+			assert.Equal(t, test.rule, err.(*PolicyError).Rule)
+		}
+	}
+
+	_, err = ParsePolicy([]byte(`{"Rules": [{"MaxMemory": "lots"}]}`))
+	assert.Error(t, err)
+	_, err = ParsePolicy([]byte(`{"Rules": [`))
+	assert.Error(t, err)
+}
+
+func TestPolicyFileReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-policy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.json")
+
+	config := BuildContainerConfig(containertypes.Config{Image: "nginx"}, containertypes.HostConfig{Privileged: true}, networktypes.NetworkingConfig{})
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"Rules": []}`), 0644))
+	f, err := NewPolicyFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Check(config))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"Rules": [{"DenyPrivileged": true}]}`), 0644))
+	assert.NoError(t, f.Reload())
+	assert.Error(t, f.Check(config))
+
+	// Invalid files don't replace the current policy.
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{`), 0644))
+	assert.Error(t, f.Reload())
+	assert.Error(t, f.Check(config))
+}
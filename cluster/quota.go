@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	units "github.com/docker/go-units"
+)
+
+// Quota limits the containers of a tenant across the cluster. Zero values
+// mean no limit.
+type Quota struct {
+	Containers int64
+	Memory     int64
+	Cpus       int64
+}
+
+// QuotaUsage is the quota of a tenant, if any, along with the resources its
+// containers reserve.
+type QuotaUsage struct {
+	Tenant string
+	Quota  *Quota
+	Used   Quota
+}
+
+// QuotaError is returned when a container would exceed the quota of its
+// tenant.
+type QuotaError struct {
+	Tenant string
+	Reason string
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota of tenant %s exceeded: %s", e.Tenant, e.Reason)
+}
+
+// Add accounts for the resources reserved by a container.
+func (q *Quota) Add(config *ContainerConfig) {
+	q.Containers++
+	q.Memory += config.ReservedMemory()
+	q.Cpus += config.ReservedCpus()
+}
+
+// Check returns a *QuotaError if the used resources exceed the quota.
+func (q *Quota) Check(tenant string, used Quota) error {
+	switch {
+	case q.Containers > 0 && used.Containers > q.Containers:
+		return &QuotaError{tenant, fmt.Sprintf("%d containers, the maximum is %d", used.Containers, q.Containers)}
+	case q.Memory > 0 && used.Memory > q.Memory:
+		return &QuotaError{tenant, fmt.Sprintf("%s of reserved memory, the maximum is %s", units.BytesSize(float64(used.Memory)), units.BytesSize(float64(q.Memory)))}
+	case q.Cpus > 0 && used.Cpus > q.Cpus:
+		return &QuotaError{tenant, fmt.Sprintf("%d reserved CPUs, the maximum is %d", used.Cpus, q.Cpus)}
+	}
+	return nil
+}
+
+// QuotaStore holds the quotas of the tenants, saved in a backend which may be
+// shared by the replicated managers.
+type QuotaStore struct {
+	mu      sync.Mutex
+	backend Backend
+}
+
+// NewQuotaStore creates a quota store saving the quotas in backend.
+func NewQuotaStore(backend Backend) (*QuotaStore, error) {
+	s := &QuotaStore{backend: backend}
+	// Fail early with invalid quotas.
+	if _, err := s.Quotas(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Quotas returns the quotas of the tenants.
+func (s *QuotaStore) Quotas() (map[string]*Quota, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Set sets the quota of a tenant. nil removes it.
+func (s *QuotaStore) Set(tenant string, quota *Quota) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	quotas, err := s.load()
+	if err != nil {
+		return err
+	}
+	if quota == nil {
+		delete(quotas, tenant)
+	} else {
+		q := *quota
+		quotas[tenant] = &q
+	}
+	data, err := json.Marshal(quotas)
+	if err != nil {
+		return err
+	}
+	return s.backend.Save(data)
+}
+
+func (s *QuotaStore) load() (map[string]*Quota, error) {
+	quotas := make(map[string]*Quota)
+	data, err := s.backend.Load()
+	if err != nil || data == nil {
+		return quotas, err
+	}
+	if err := json.Unmarshal(data, &quotas); err != nil {
+		return nil, fmt.Errorf("invalid quotas: %v", err)
+	}
+	return quotas, nil
+}
@@ -0,0 +1,39 @@
+package cluster
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-quotas")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "quotas")
+
+	s, err := NewQuotaStore(NewFileBackend(path))
+	assert.NoError(t, err)
+	quotas, err := s.Quotas()
+	assert.NoError(t, err)
+	assert.Empty(t, quotas)
+
+	assert.NoError(t, s.Set("web", &Quota{Containers: 2, Memory: 4096}))
+	assert.NoError(t, s.Set("batch", &Quota{Cpus: 4}))
+	assert.NoError(t, s.Set("batch", nil))
+
+	// Quotas are kept across restarts.
+	other, err := NewQuotaStore(NewFileBackend(path))
+	assert.NoError(t, err)
+	quotas, err = other.Quotas()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*Quota{"web": {Containers: 2, Memory: 4096}}, quotas)
+
+	// Invalid files are refused.
+	assert.NoError(t, ioutil.WriteFile(path, []byte("{"), 0600))
+	_, err = NewQuotaStore(NewFileBackend(path))
+	assert.Error(t, err)
+}
@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"os/signal"
 	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -24,9 +27,27 @@ import (
 	"github.com/docker/swarm/cluster"
 	"github.com/docker/swarm/scheduler"
 	"github.com/docker/swarm/scheduler/node"
+	"github.com/opencontainers/image-spec/specs-go/v1"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// maxPlacementAttempts is the number of optimistic placements attempted
+	// before falling back to a placement under the reservation lock.
+	maxPlacementAttempts = 5
+)
+
+var (
+	errReservationConflict = errors.New("reservation conflict")
+	// errStaleSnapshot is returned when the selected engine changed since
+	// the placement was computed, which may not be the best anymore.
+	errStaleSnapshot = errors.New("stale node snapshot")
+)
+
+func errNameConflict(name string) error {
+	return fmt.Errorf("Conflict: The name %s is already assigned. You have to delete (or rename) that container to be able to assign %s to a container again.", name, name)
+}
+
 type pendingContainer struct {
 	Config *cluster.ContainerConfig
 	Name   string
@@ -60,17 +81,48 @@ type Cluster struct {
 
 	cluster.ClusterEventHandlers
 
-	engines           map[string]*cluster.Engine
-	pendingEngines    map[string]*cluster.Engine
-	scheduler         *scheduler.Scheduler
-	discovery         discovery.Backend
+	engines        map[string]*cluster.Engine
+	pendingEngines map[string]*cluster.Engine
+	containers     *cluster.ContainerIndex
+	scheduler      *scheduler.Scheduler
+	discovery      discovery.Backend
+	builds         *buildSyncer
+
+	// pendingMu protects pendingContainers and generations. It must be
+	// acquired before the cluster lock when both are needed.
+	pendingMu         sync.Mutex
 	pendingContainers map[string]*pendingContainer
-	builds            *buildSyncer
+	// generations is incremented for an engine each time a container is
+	// reserved on it, to detect conflicting placements.
+	generations map[string]uint64
 
 	overcommitRatio float64
 	engineOpts      *cluster.EngineOpts
 	createRetry     int64
 	TLSConfig       *tls.Config
+	// crossNodeLinks enables links to containers on other engines, through
+	// host entries and environment variables.
+	crossNodeLinks bool
+	// portRange is the default range of host ports allocated to containers.
+	portRange *portRange
+	// admission validates and mutates container configurations before they
+	// get scheduled, if admission webhooks are configured.
+	admission *cluster.Admission
+	// policy is the policy container configurations have to comply with, if
+	// any. It is reloaded on SIGHUP.
+	policy *cluster.PolicyFile
+	// limits are the default and maximum resources of the containers, if
+	// any.
+	limits *cluster.ResourceLimits
+	// quotaLabel is the container label identifying tenants, if quotas are
+	// enabled. quotas are the quotas last loaded from quotaStore, protected
+	// by pendingMu.
+	quotaLabel string
+	quotaStore *cluster.QuotaStore
+	quotas     map[string]*cluster.Quota
+	// credentials are the registry credentials used when clients send none,
+	// if a credential store is configured.
+	credentials *cluster.CredentialStore
 }
 
 // NewCluster is exported.
@@ -81,14 +133,17 @@ func NewCluster(scheduler *scheduler.Scheduler, TLSConfig *tls.Config, discovery
 		ClusterEventHandlers: cluster.NewClusterEventHandlers(),
 		engines:              make(map[string]*cluster.Engine),
 		pendingEngines:       make(map[string]*cluster.Engine),
+		containers:           cluster.NewContainerIndex(),
 		scheduler:            scheduler,
 		TLSConfig:            TLSConfig,
 		discovery:            discovery,
 		pendingContainers:    make(map[string]*pendingContainer),
+		generations:          make(map[string]uint64),
 		overcommitRatio:      0.05,
 		engineOpts:           engineOptions,
 		createRetry:          0,
 		builds:               newBuildSyncer(),
+		quotas:               make(map[string]*cluster.Quota),
 	}
 
 	if val, ok := options.Float("swarm.overcommit", ""); ok {
@@ -109,6 +164,44 @@ func NewCluster(scheduler *scheduler.Scheduler, TLSConfig *tls.Config, discovery
 		cluster.createRetry = val
 	}
 
+	if val, ok := options.String("swarm.portrange", ""); ok {
+		r, err := parsePortRange(val)
+		if err != nil {
+			log.Fatalf("swarm.portrange should be a range of ports such as 30000-32767, %s is invalid", val)
+		}
+		cluster.portRange = r
+	}
+
+	if val, ok := options.String("swarm.quota.label", ""); ok {
+		cluster.quotaLabel = val
+	}
+
+	if val, ok := options.Bool("swarm.crossnodelinks", ""); ok {
+		cluster.crossNodeLinks = val
+	}
+
+	if val, ok := options.Bool("swarm.ignorestopped", ""); ok && engineOptions != nil {
+		// Don't modify the options shared with the caller.
+		opts := *engineOptions
+		opts.IgnoreStoppedContainers = val
+		cluster.engineOpts = &opts
+	}
+
+	cluster.admission = newAdmission(options)
+
+	cluster.limits = newResourceLimits(options)
+
+	cluster.credentials = newCredentialStore(options, cluster.discovery)
+
+	if cluster.quotaLabel != "" {
+		cluster.quotaStore = newQuotaStore(options, cluster.discovery)
+		cluster.refreshQuotas()
+	}
+
+	if cluster.policy = newPolicy(options); cluster.policy != nil {
+		go cluster.reloadPolicyOnSignal()
+	}
+
 	discoveryCh, errCh := cluster.discovery.Watch(nil)
 	go cluster.monitorDiscovery(discoveryCh, errCh)
 	go cluster.monitorPendingEngines()
@@ -116,6 +209,74 @@ func NewCluster(scheduler *scheduler.Scheduler, TLSConfig *tls.Config, discovery
 	return cluster, nil
 }
 
+// newAdmission creates the admission webhooks client configured by the
+// swarm.admission.* options, if any.
+func newAdmission(options cluster.DriverOpts) *cluster.Admission {
+	var mutating, validating []string
+	if val, ok := options.String("swarm.admission.mutate", ""); ok && val != "" {
+		mutating = strings.Split(val, ",")
+	}
+	if val, ok := options.String("swarm.admission.validate", ""); ok && val != "" {
+		validating = strings.Split(val, ",")
+	}
+	if len(mutating) == 0 && len(validating) == 0 {
+		return nil
+	}
+
+	timeout := cluster.DefaultAdmissionTimeout
+	if val, ok := options.String("swarm.admission.timeout", ""); ok {
+		d, err := time.ParseDuration(val)
+		if err != nil || d <= 0 {
+			log.Fatalf("swarm.admission.timeout should be a positive duration, %s is invalid", val)
+		}
+		timeout = d
+	}
+	failOpen, _ := options.Bool("swarm.admission.failopen", "")
+	return cluster.NewAdmission(mutating, validating, timeout, failOpen)
+}
+
+// newPolicy loads the policy file configured by the swarm.policy option, if
+// any.
+func newPolicy(options cluster.DriverOpts) *cluster.PolicyFile {
+	val, ok := options.String("swarm.policy", "")
+	if !ok || val == "" {
+		return nil
+	}
+	policy, err := cluster.NewPolicyFile(val)
+	if err != nil {
+		log.Fatalf("swarm.policy: unable to load %s: %v", val, err)
+	}
+	return policy
+}
+
+// newResourceLimits loads the resource limits configured by the
+// swarm.limits option, if any.
+func newResourceLimits(options cluster.DriverOpts) *cluster.ResourceLimits {
+	val, ok := options.String("swarm.limits", "")
+	if !ok || val == "" {
+		return nil
+	}
+	limits, err := cluster.LoadResourceLimits(val)
+	if err != nil {
+		log.Fatalf("swarm.limits: unable to load %s: %v", val, err)
+	}
+	return limits
+}
+
+// reloadPolicyOnSignal reloads the policy file whenever the manager receives
+// SIGHUP.
+func (c *Cluster) reloadPolicyOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		if err := c.policy.Reload(); err != nil {
+			log.WithField("error", err).Error("Failed to reload policy, keeping the current one")
+			continue
+		}
+		log.Info("Policy reloaded")
+	}
+}
+
 // NewAPIEventHandler creates a new API events handler
 func (c *Cluster) NewAPIEventHandler() *cluster.APIEventHandler {
 	return cluster.NewAPIEventHandler()
@@ -138,10 +299,50 @@ func (c *Cluster) StartContainer(container *cluster.Container) error {
 
 // CreateContainer aka schedule a brand new container into the cluster.
 func (c *Cluster) CreateContainer(config *cluster.ContainerConfig, name string, authConfig *types.AuthConfig) (*cluster.Container, error) {
+	// Default limits are set before the admission webhooks and the policy
+	// see the container, the maximums are checked once they are done.
+	if c.limits != nil {
+		c.limits.ApplyDefaults(config)
+	}
+
+	if c.admission != nil {
+		if err := c.admission.Admit(config, name); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.limits != nil {
+		if err := c.limits.Check(config); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.policy != nil {
+		if err := c.policy.Check(config); err != nil {
+			return nil, err
+		}
+	}
+
+	// The quotas are checked when the container is reserved, under a lock
+	// the store must not be read under.
+	c.refreshQuotas()
+
+	if c.crossNodeLinks {
+		c.convertLinks(config, name)
+	}
+
+	// Clients may not send credentials, and the watchdog never does when
+	// rescheduling containers: fall back to the manager credentials.
+	authConfig = c.registryAuth(config.Image, authConfig)
+
 	// engines newer than api version 1.30 have a /distribution/{name:.*}/json
 	// endpoint, which can be used to contact a registry and determine the
-	// image platforms. before starting a container, fill in the constraint.
-	c.setOSTypeConstraint(config, authConfig)
+	// image platforms. before starting a container, fill in the constraints.
+	if err := c.setPlatformConstraints(config, authConfig); err != nil {
+		if _, ok := err.(noPlatformError); ok {
+			return nil, err
+		}
+	}
 
 	container, err := c.createContainer(config, name, false, authConfig)
 
@@ -182,21 +383,73 @@ func (c *Cluster) CreateContainer(config *cluster.ContainerConfig, name string,
 	return container, err
 }
 
-// setOSTypeConstraint chooses an engine and leverages its /distribution
-// endpoint to determine a list of compatible Platforms for the image. it then
-// adds an ostype constraint for the valid OS types. If an ostype constraint
-// already exists on container, then this will not overwrite it.
-func (c *Cluster) setOSTypeConstraint(config *cluster.ContainerConfig, authConfig *types.AuthConfig) error {
-	// first, check if there is an existing ostype constraint. if so, leave this
-	// alone and take no action.
-	constraints := config.Constraints()
-	for _, constraint := range constraints {
-		if strings.Contains(constraint, "ostype") {
-			return nil
+// noPlatformError is returned when none of the engines of the cluster can run
+// any of the platforms of an image.
+type noPlatformError struct {
+	image     string
+	platforms []v1.Platform
+}
+
+func (err noPlatformError) Error() string {
+	platforms := make([]string, 0, len(err.platforms))
+	for _, p := range err.platforms {
+		platforms = append(platforms, cluster.PlatformString(p))
+	}
+	return fmt.Sprintf("no node in the cluster can run image %s, available platforms: %s", err.image, strings.Join(platforms, ", "))
+}
+
+// constraintValue returns a constraint value matching exactly one of the
+// given values.
+func constraintValue(values map[string]struct{}) string {
+	// if there is only one value, then we can just use it. otherwise, we
+	// need to build an anchored regex matching any of them.
+	if len(values) == 1 {
+		// iterate, which is how we get a map key that we don't already know
+		for value := range values {
+			return value
 		}
 	}
 
-	// now that we know we have to set an os constraint, choose an engine at
+	// first, turn the map into a slice of parenthesized strings. strictly
+	// speaking, we don't HAVE to put parentheses, but it's better to be
+	// explicity than to rely on regex order of operations
+	var strs []string
+	for value := range values {
+		strs = append(strs, fmt.Sprintf("(%s)", value))
+	}
+
+	// then, string join the resulting slice with | and pack it in between /
+	// characters to denote that it's a regular expression
+	return fmt.Sprintf("/%s/", strings.Join(strs, "|"))
+}
+
+// hasConstraint returns true if the config already has a constraint on key.
+func hasConstraint(config *cluster.ContainerConfig, key string) bool {
+	for _, constraint := range config.Constraints() {
+		if strings.Contains(constraint, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// setPlatformConstraints chooses an engine and leverages its /distribution
+// endpoint to determine a list of compatible Platforms for the image. it then
+// records them on the config, for the platform filter to keep the nodes
+// running one of them, and adds an ostype constraint for the valid OS types.
+// An existing ostype constraint is not overwritten. A noPlatformError is
+// returned if no engine can run any of the platforms.
+func (c *Cluster) setPlatformConstraints(config *cluster.ContainerConfig, authConfig *types.AuthConfig) error {
+	config.Platforms = nil
+
+	// first, check if there are existing ostype and architecture
+	// constraints. if so, leave them alone and take no action.
+	setOSType := !hasConstraint(config, "ostype")
+	if !setOSType && hasConstraint(config, "architecture") {
+		return nil
+	}
+
+	// now that we know we have to look the platforms up, choose an engine at
 	// random. any engine should theoretically be able to contact the registry
 	engine, err := c.RANDOMENGINE()
 	if err != nil {
@@ -208,55 +461,114 @@ func (c *Cluster) setOSTypeConstraint(config *cluster.ContainerConfig, authConfi
 	if err != nil {
 		return err
 	}
+	if len(platforms) == 0 {
+		return nil
+	}
+
+	// make sure at least one engine can run one of the platforms, so that
+	// the user gets a clear error rather than a failure to start.
+	supported := false
+	for _, e := range c.listActiveEngines() {
+		for _, p := range platforms {
+			if cluster.PlatformSupported(e.Labels, p) {
+				supported = true
+				break
+			}
+		}
+		if supported {
+			break
+		}
+	}
+	if !supported {
+		return noPlatformError{image: config.Image, platforms: platforms}
+	}
+	config.Platforms = platforms
 
 	// now extract the OSes. use a map to deduplicate, in case the image is
-	// available on several architectures with the same platform.
+	// available on several architectures with the same OS.
 	ostypes := map[string]struct{}{}
 	for _, p := range platforms {
-		ostypes[p.OS] = struct{}{}
+		if p.OS != "" {
+			ostypes[p.OS] = struct{}{}
+		}
 	}
 
-	// if there is only one OS type, then we can just set a constraint. if the
-	// image is available on more OS types, then we need to build a regex for
-	// the constraint, which is more complicated. if for some reason there are
-	// 0 valid OS types, then just return without doing anything.
-	if len(ostypes) == 1 {
-		// iterate, which is how we get a map key that we don't already know
-		var ostype string
-		for os := range ostypes {
-			ostype = os
-		}
-		config.AddConstraint("ostype==" + ostype)
-	} else if len(ostypes) > 1 {
-		// first, turn the map into a slice of parenthesized strings. strictly
-		// speaking, we don't HAVE to put parentheses, but it's better to be
-		// explicity than to rely on regex order of operations
-		var osStrings []string
-		for os := range ostypes {
-			osStrings = append(osStrings, fmt.Sprintf("(%s)", os))
-		}
+	// if for some reason there are 0 valid OS types, then don't set the
+	// constraint.
+	if setOSType && len(ostypes) > 0 {
+		config.AddConstraint("ostype==" + constraintValue(ostypes))
+	}
+	return nil
+}
+
+// CheckContainerUpdate returns a *cluster.PolicyError if the container would
+// no longer comply with the policy once its resources are updated, or a
+// *cluster.QuotaError if the update would exceed the quota of its tenant.
+func (c *Cluster) CheckContainerUpdate(container *cluster.Container, update containertypes.UpdateConfig) error {
+	if container.Config == nil || (c.policy == nil && c.limits == nil && c.quotaLabel == "") {
+		return nil
+	}
 
-		// then, string join the resulting slice with |
-		osStringsJoined := strings.Join(osStrings, "|")
+	// Apply the update to a copy of the configuration. Zero values leave
+	// the current settings unchanged.
+	config := *container.Config
+	resources := update.Resources
+	if resources.Memory != 0 {
+		config.HostConfig.Memory = resources.Memory
+	}
+	if resources.MemoryReservation != 0 {
+		config.HostConfig.MemoryReservation = resources.MemoryReservation
+	}
+	if resources.NanoCPUs != 0 {
+		config.HostConfig.NanoCPUs = resources.NanoCPUs
+	}
+	if resources.CPUQuota != 0 {
+		config.HostConfig.CPUQuota = resources.CPUQuota
+	}
+	if resources.CPUPeriod != 0 {
+		config.HostConfig.CPUPeriod = resources.CPUPeriod
+	}
+	if resources.CpusetCpus != "" {
+		config.HostConfig.CpusetCpus = resources.CpusetCpus
+	}
+	if resources.CPUShares != 0 && container.Engine != nil {
+		// Updates go straight to the engine, in CPU shares.
+		config.HostConfig.CPUShares = resources.CPUShares * container.Engine.Cpus / 1024
+	}
 
-		// and finally, pack it in between / characters to denote that it's a
-		// regular expression
-		osRegex := fmt.Sprintf("/%s/", osStringsJoined)
+	if c.limits != nil {
+		if err := c.limits.Check(&config); err != nil {
+			return err
+		}
+	}
+	if c.policy != nil {
+		if err := c.policy.Check(&config); err != nil {
+			return err
+		}
+	}
+	if c.quotaLabel == "" {
+		return nil
+	}
 
-		// and add it as a constraint
-		config.AddConstraint("ostype==" + osRegex)
+	// Containers created without Swarm ID are identified by their ID.
+	skipID := container.Config.SwarmID()
+	if skipID == "" {
+		skipID = container.ID
 	}
 
-	return nil
+	c.refreshQuotas()
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	return c.checkQuotaLocked(&config, skipID)
 }
 
 func (c *Cluster) createContainer(config *cluster.ContainerConfig, name string, withImageAffinity bool, authConfig *types.AuthConfig) (*cluster.Container, error) {
-	c.scheduler.Lock()
-
-	// Ensure the name is available
+	// Ensure the name is available. This is checked again when the
+	// reservation is committed, since another create may have taken it in
+	// the meantime.
 	if !c.checkNameUniqueness(name) {
-		c.scheduler.Unlock()
-		return nil, fmt.Errorf("Conflict: The name %s is already assigned. You have to delete (or rename) that container to be able to assign %s to a container again.", name, name)
+		return nil, errNameConflict(name)
 	}
 
 	swarmID := config.SwarmID()
@@ -277,30 +589,15 @@ func (c *Cluster) createContainer(config *cluster.ContainerConfig, name string,
 		config.AddAffinity("image==" + config.Image)
 	}
 
-	nodes, err := c.scheduler.SelectNodesForContainer(c.listNodes(), config)
+	n, engine, err := c.placeContainer(config, name, swarmID)
 
 	if withImageAffinity {
 		config.RemoveAffinity("image==" + config.Image)
 	}
 
 	if err != nil {
-		c.scheduler.Unlock()
 		return nil, err
 	}
-	n := nodes[0]
-	engine, ok := c.engines[n.ID]
-	if !ok {
-		c.scheduler.Unlock()
-		return nil, fmt.Errorf("error creating container")
-	}
-
-	c.pendingContainers[swarmID] = &pendingContainer{
-		Name:   name,
-		Config: config,
-		Engine: engine,
-	}
-
-	c.scheduler.Unlock()
 
 	container, err := engine.CreateContainer(config, name, true, authConfig)
 
@@ -314,13 +611,148 @@ func (c *Cluster) createContainer(config *cluster.ContainerConfig, name string,
 		log.WithFields(log.Fields{"NodeName": n.Name, "NodeID": n.ID}).Debugf("Scheduling container %s to ", containerFlag)
 	}
 
-	c.scheduler.Lock()
+	c.pendingMu.Lock()
 	delete(c.pendingContainers, swarmID)
-	c.scheduler.Unlock()
+	c.pendingMu.Unlock()
 
 	return container, err
 }
 
+// placeContainer selects a node for the container and reserves its resources
+// there by registering a pending container.
+//
+// Filters and strategies run on a snapshot of the nodes without holding any
+// lock, so that concurrent creates don't queue behind each other. The
+// reservation is then committed optimistically: if another placement reserved
+// resources on the selected engine since the snapshot was taken, the placement
+// is computed again on a fresh snapshot, so that concurrent placements are
+// ranked against each other. When the reservation conflicts, the placement is
+// retried without the nodes that conflicted. After maxPlacementAttempts
+// attempts, or once every node conflicted, the placement falls back to
+// running entirely under the reservation lock.
+func (c *Cluster) placeContainer(config *cluster.ContainerConfig, name, swarmID string) (*node.Node, *cluster.Engine, error) {
+	nodes, generations := c.snapshotNodes()
+
+	// The extenders are called once, without holding any lock: their
+	// decision holds for all the attempts.
+	decision, err := c.scheduler.CallExtenders(nodes, config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	excluded := make(map[string]bool)
+	for attempt := 1; attempt <= maxPlacementAttempts; attempt++ {
+		if attempt > 1 {
+			nodes, generations = c.snapshotNodes()
+		}
+		if nodes = withoutNodes(nodes, excluded); len(nodes) == 0 {
+			break
+		}
+		candidates, err := c.scheduler.SelectNodesWithExtenders(nodes, config, decision)
+		if err != nil {
+			return nil, nil, err
+		}
+		n := candidates[0]
+
+		engine, err := c.reserve(n, config, name, swarmID, generations[n.ID])
+		if err == errStaleSnapshot {
+			log.WithFields(log.Fields{"NodeName": n.Name, "NodeID": n.ID, "attempt": attempt}).Debug("Node changed since the placement, retrying placement")
+			continue
+		}
+		if err != errReservationConflict {
+			return n, engine, err
+		}
+		excluded[n.ID] = true
+		log.WithFields(log.Fields{"NodeName": n.Name, "NodeID": n.ID, "attempt": attempt}).Debug("Reservation conflict, retrying placement")
+	}
+
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	nodes, _ = c.listNodesLocked()
+	candidates, err := c.scheduler.SelectNodesWithExtenders(nodes, config, decision)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Under the lock, only an exhausted port range makes a reservation
+	// conflict: try the next best node.
+	for _, n := range candidates {
+		engine, err := c.reserveLocked(n, config, name, swarmID)
+		if err != errReservationConflict {
+			return n, engine, err
+		}
+	}
+	return nil, nil, errNoFreePort
+}
+
+// withoutNodes returns the nodes whose ID isn't excluded.
+func withoutNodes(nodes []*node.Node, excluded map[string]bool) []*node.Node {
+	if len(excluded) == 0 {
+		return nodes
+	}
+	kept := make([]*node.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if !excluded[n.ID] {
+			kept = append(kept, n)
+		}
+	}
+	return kept
+}
+
+// reserve commits the placement of a container on node n, provided that the
+// node didn't change since the placement. generation is the reservation
+// generation of the engine at the time the node snapshot was taken.
+func (c *Cluster) reserve(n *node.Node, config *cluster.ContainerConfig, name, swarmID string, generation uint64) (*cluster.Engine, error) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	if c.generations[n.ID] != generation {
+		// Other containers were reserved on this engine in the meantime:
+		// it may not pass the filters anymore, nor be the best node.
+		return nil, errStaleSnapshot
+	}
+
+	return c.reserveLocked(n, config, name, swarmID)
+}
+
+// reserveLocked registers a pending container on the engine backing node n.
+// pendingMu must be held.
+func (c *Cluster) reserveLocked(n *node.Node, config *cluster.ContainerConfig, name, swarmID string) (*cluster.Engine, error) {
+	if !c.isNameAvailableLocked(name) {
+		return nil, errNameConflict(name)
+	}
+
+	engine := c.getEngine(n.ID)
+	if engine == nil {
+		return nil, fmt.Errorf("error creating container")
+	}
+
+	// Rescheduled containers replace their previous instance.
+	if err := c.checkQuotaLocked(config, swarmID); err != nil {
+		return nil, err
+	}
+
+	// Allocate the host ports against the current state of the engine, so
+	// that concurrent placements don't pick the same ports. Another node may
+	// still have free ports.
+	if err := c.allocatePortsLocked(engine, config); err != nil {
+		if _, ok := err.(noFreePortError); ok {
+			log.WithFields(log.Fields{"NodeName": n.Name, "NodeID": n.ID}).Debug(err)
+			return nil, errReservationConflict
+		}
+		return nil, err
+	}
+
+	c.pendingContainers[swarmID] = &pendingContainer{
+		Name:   name,
+		Config: config,
+		Engine: engine,
+	}
+	c.generations[engine.ID]++
+
+	return engine, nil
+}
+
 // RemoveContainer aka Remove a container from the cluster.
 func (c *Cluster) RemoveContainer(container *cluster.Container, force, volumes bool) error {
 	return container.Engine.RemoveContainer(container, force, volumes)
@@ -444,6 +876,7 @@ func (c *Cluster) validatePendingEngine(engine *cluster.Engine) bool {
 	// set engine state to healthy, and start refresh loop
 	engine.ValidationComplete()
 	c.engines[engine.ID] = engine
+	engine.SetContainerIndex(c.containers)
 
 	log.Infof("Registered Engine %s at %s", engine.Name, engine.Addr)
 	return true
@@ -463,6 +896,7 @@ func (c *Cluster) removeEngine(addr string) bool {
 		delete(c.pendingEngines, addr)
 	} else {
 		delete(c.engines, engine.ID)
+		engine.SetContainerIndex(nil)
 	}
 	log.Infof("Removed Engine %s", engine.Name)
 	return true
@@ -718,6 +1152,8 @@ func (c *Cluster) RemoveVolumes(name string) (bool, error) {
 func (c *Cluster) Pull(name string, authConfig *types.AuthConfig, callback func(msg cluster.JSONMessageWrapper)) {
 	var wg sync.WaitGroup
 
+	authConfig = c.registryAuth(name, authConfig)
+
 	for _, e := range c.listActiveEngines() {
 		wg.Add(1)
 
@@ -912,18 +1348,33 @@ func (c *Cluster) Containers() cluster.Containers {
 }
 
 func (c *Cluster) checkNameUniqueness(name string) bool {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	return c.isNameAvailableLocked(name)
+}
+
+// isNameAvailableLocked returns true if no container or pending container
+// uses name. pendingMu must be held.
+func (c *Cluster) isNameAvailableLocked(name string) bool {
 	// Abort immediately if the name is empty.
 	if len(name) == 0 {
 		return true
 	}
 
-	c.RLock()
-	defer c.RUnlock()
-	for _, e := range c.engines {
-		for _, c := range e.Containers() {
-			for _, cname := range c.Names {
-				if cname == name || cname == "/"+name {
-					return false
+	if c.containers != nil {
+		if c.containers.HasName(name) {
+			return false
+		}
+	} else {
+		c.RLock()
+		defer c.RUnlock()
+		for _, e := range c.engines {
+			for _, c := range e.Containers() {
+				for _, cname := range c.Names {
+					if cname == name || cname == "/"+name {
+						return false
+					}
 				}
 			}
 		}
@@ -945,7 +1396,19 @@ func (c *Cluster) Container(IDOrName string) *cluster.Container {
 	if len(IDOrName) == 0 {
 		return nil
 	}
-	return c.Containers().Get(IDOrName)
+	if c.containers == nil {
+		return c.Containers().Get(IDOrName)
+	}
+	return c.containers.Get(IDOrName)
+}
+
+// LookupContainers returns the containers having one of the given names and
+// all the given labels, using the container index.
+func (c *Cluster) LookupContainers(names, labels []string) cluster.Containers {
+	if c.containers == nil {
+		return c.Containers()
+	}
+	return c.containers.Lookup(names, labels)
 }
 
 // Networks returns all the networks in the cluster.
@@ -976,21 +1439,53 @@ func (c *Cluster) Volumes() cluster.Volumes {
 
 // listNodes returns all validated engines in the cluster, excluding pendingEngines.
 func (c *Cluster) listNodes() []*node.Node {
+	nodes, _ := c.snapshotNodes()
+	return nodes
+}
+
+// snapshotNodes returns all validated engines in the cluster along with the
+// reservation generation of each of them at the time of the snapshot.
+func (c *Cluster) snapshotNodes() ([]*node.Node, map[string]uint64) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	return c.listNodesLocked()
+}
+
+// listNodesLocked is the implementation of snapshotNodes. pendingMu must be
+// held.
+func (c *Cluster) listNodesLocked() ([]*node.Node, map[string]uint64) {
 	c.RLock()
 	defer c.RUnlock()
 
 	out := make([]*node.Node, 0, len(c.engines))
+	generations := make(map[string]uint64, len(c.engines))
 	for _, e := range c.engines {
-		node := node.NewNode(e)
-		for _, pc := range c.pendingContainers {
-			if pc.Engine.ID == e.ID && node.Container(pc.Config.SwarmID()) == nil {
-				node.AddContainer(pc.ToContainer())
-			}
+		out = append(out, c.newNodeLocked(e))
+		generations[e.ID] = c.generations[e.ID]
+	}
+
+	return out, generations
+}
+
+// newNodeLocked creates a node from an engine, accounting for the containers
+// pending creation on it. pendingMu must be held.
+func (c *Cluster) newNodeLocked(e *cluster.Engine) *node.Node {
+	node := node.NewNode(e)
+	for _, pc := range c.pendingContainers {
+		if pc.Engine.ID == e.ID && node.Container(pc.Config.SwarmID()) == nil {
+			node.AddContainer(pc.ToContainer())
 		}
-		out = append(out, node)
 	}
+	return node
+}
 
-	return out
+// getEngine returns the validated engine with the given ID, if any.
+func (c *Cluster) getEngine(ID string) *cluster.Engine {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.engines[ID]
 }
 
 // listEngines returns all the engines in the cluster.
@@ -1052,8 +1547,11 @@ func (c *Cluster) Info() [][2]string {
 	info := [][2]string{
 		{"Strategy", c.scheduler.Strategy()},
 		{"Filters", c.scheduler.Filters()},
-		{"Nodes", fmt.Sprintf("%d", len(c.engines)+len(c.pendingEngines))},
 	}
+	for _, pool := range c.scheduler.Pools() {
+		info = append(info, [2]string{"  └ Pool", pool})
+	}
+	info = append(info, [2]string{"Nodes", fmt.Sprintf("%d", len(c.engines)+len(c.pendingEngines))})
 
 	engines := c.listEngines()
 	sort.Sort(cluster.EngineSorter(engines))
@@ -1085,7 +1583,19 @@ func (c *Cluster) Info() [][2]string {
 		}
 
 		info = append(info, [2]string{"  └ Reserved CPUs", fmt.Sprintf("%d / %d", engine.UsedCpus(), engine.TotalCpus())})
+		if pinned := engine.PinnedCpus(); pinned > 0 {
+			info = append(info, [2]string{"  └ Pinned CPUs", fmt.Sprintf("%d / %d", pinned, engine.Cpus)})
+		}
 		info = append(info, [2]string{"  └ Reserved Memory", fmt.Sprintf("%s / %s", units.BytesSize(float64(engine.UsedMemory())), units.BytesSize(float64(engine.TotalMemory())))})
+		if total := engine.TotalResources(); len(total) > 0 {
+			used := engine.UsedResources()
+			resources := make([]string, 0, len(total))
+			for name, count := range total {
+				resources = append(resources, fmt.Sprintf("%s: %d / %d", name, used[name], count))
+			}
+			sort.Strings(resources)
+			info = append(info, [2]string{"  └ Reserved Resources", strings.Join(resources, ", ")})
+		}
 		labels := make([]string, 0, len(engine.Labels))
 		for k, v := range engine.Labels {
 			labels = append(labels, k+"="+v)
@@ -1116,7 +1626,7 @@ func (c *Cluster) RANDOMENGINE() (*cluster.Engine, error) {
 func (c *Cluster) RenameContainer(container *cluster.Container, newName string) error {
 	// check new name whether available
 	if !c.checkNameUniqueness(newName) {
-		return fmt.Errorf("Conflict: The name %s is already assigned. You have to delete (or rename) that container to be able to assign %s to a container again.", newName, newName)
+		return errNameConflict(newName)
 	}
 
 	// call engine rename
@@ -1181,9 +1691,7 @@ func (c *Cluster) BuildImage(buildContext io.Reader, buildImage *types.ImageBuil
 		)
 
 		buildImage.BuildArgs = convertKVStringsToMap(config.Env)
-		c.scheduler.Lock()
 		nodes, err := c.scheduler.SelectNodesForContainer(c.listNodes(), config)
-		c.scheduler.Unlock()
 		if err != nil {
 			return err
 		}
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,6 +17,7 @@ import (
 	networktypes "github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/go-connections/nat"
 	engineapimock "github.com/docker/swarm/api/mockclient"
 	"github.com/docker/swarm/cluster"
 	"github.com/docker/swarm/scheduler"
@@ -280,7 +284,7 @@ func TestSetOsTypeConstraint(t *testing.T) {
 		engines: make(map[string]*cluster.Engine),
 	}
 
-	// because setOSTypeConstraint uses RANDOMENGINE, we need to initialize a
+	// because setPlatformConstraints uses RANDOMENGINE, we need to initialize a
 	// scheduler. it doesn't actually DO anything, but it cannot be nil. and to
 	// initialize a scheduler, we first need to initialize a strategy and a
 	// filter.
@@ -314,8 +318,8 @@ func TestSetOsTypeConstraint(t *testing.T) {
 		// set the engine we created to use the mock client
 		e.ConnectWithClient(apiClient)
 
-		// and then try doing setOSTypeConstraint
-		err := c.setOSTypeConstraint(config, nil)
+		// and then try doing setPlatformConstraints
+		err := c.setPlatformConstraints(config, nil)
 		assert.Nil(t, err)
 
 		c, ok := getOSTypeConstraint(config)
@@ -338,7 +342,7 @@ func TestSetOsTypeConstraint(t *testing.T) {
 
 		e.ConnectWithClient(apiClient)
 
-		err := c.setOSTypeConstraint(config, nil)
+		err := c.setPlatformConstraints(config, nil)
 		assert.Nil(t, err)
 
 		c, ok := getOSTypeConstraint(config)
@@ -363,7 +367,7 @@ func TestSetOsTypeConstraint(t *testing.T) {
 
 		e.ConnectWithClient(apiClient)
 
-		err := c.setOSTypeConstraint(config, nil)
+		err := c.setPlatformConstraints(config, nil)
 		assert.Nil(t, err)
 
 		c, ok := getOSTypeConstraint(config)
@@ -392,7 +396,7 @@ func TestSetOsTypeConstraint(t *testing.T) {
 
 		e.ConnectWithClient(apiClient)
 
-		err := c.setOSTypeConstraint(config, nil)
+		err := c.setPlatformConstraints(config, nil)
 		assert.Nil(t, err)
 
 		c, ok := getOSTypeConstraint(config)
@@ -400,6 +404,240 @@ func TestSetOsTypeConstraint(t *testing.T) {
 		// the order will be random, but it should be one of these two
 		assert.Equal(t, c, "linux")
 	})
+
+	t.Run("Architectures", func(t *testing.T) {
+		config := cluster.BuildContainerConfig(containerConfig, containertypes.HostConfig{}, networktypes.NetworkingConfig{})
+
+		apiClient := mockClientWithInit()
+		apiClient.On(
+			"DistributionInspect", mock.Anything, "fooImage", mock.Anything,
+		).Return(
+			registry.DistributionInspect{
+				Platforms: []v1.Platform{
+					{OS: "linux", Architecture: "arm", Variant: "v7"},
+					{OS: "linux", Architecture: "arm", Variant: "v6"},
+				},
+			}, nil,
+		)
+
+		e.ConnectWithClient(apiClient)
+
+		err := c.setPlatformConstraints(config, nil)
+		assert.Nil(t, err)
+
+		// the architectures and variants are left to the platform filter,
+		// which matches them together.
+		constraints := config.Constraints()
+		assert.Equal(t, []string{"ostype==linux"}, constraints)
+		assert.Len(t, config.Platforms, 2)
+	})
+
+	t.Run("NoCompatiblePlatform", func(t *testing.T) {
+		config := cluster.BuildContainerConfig(containerConfig, containertypes.HostConfig{}, networktypes.NetworkingConfig{})
+
+		info := mockInfo
+		info.OSType = "linux"
+		info.Architecture = "x86_64"
+		apiClient := mockClientWithInfo(info)
+		apiClient.On(
+			"DistributionInspect", mock.Anything, "fooImage", mock.Anything,
+		).Return(
+			registry.DistributionInspect{
+				Platforms: []v1.Platform{
+					{OS: "linux", Architecture: "arm64"},
+					{OS: "windows", Architecture: "amd64"},
+				},
+			}, nil,
+		)
+
+		e.ConnectWithClient(apiClient)
+		assert.Equal(t, e.Labels["architecture"], "amd64")
+
+		err := c.setPlatformConstraints(config, nil)
+		assert.Error(t, err)
+		assert.IsType(t, noPlatformError{}, err)
+		assert.Contains(t, err.Error(), "linux/arm64, windows/amd64")
+	})
+}
+
+func TestReserveConflict(t *testing.T) {
+	strat, err := strategy.New("spread")
+	assert.Nil(t, err)
+	filters, err := filter.New([]string{"port"})
+	assert.Nil(t, err)
+
+	c := &Cluster{
+		engines:           make(map[string]*cluster.Engine),
+		scheduler:         scheduler.New(strat, filters),
+		pendingContainers: make(map[string]*pendingContainer),
+		generations:       make(map[string]uint64),
+	}
+	e := createEngine(t, "test-engine")
+	c.engines[e.ID] = e
+
+	withPort := func() *cluster.ContainerConfig {
+		return cluster.BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{
+			PortBindings: nat.PortMap{
+				nat.Port("80/tcp"): []nat.PortBinding{{HostPort: "8080"}},
+			},
+		}, networktypes.NetworkingConfig{})
+	}
+
+	// Two placements computed from the same snapshot.
+	nodes, generations := c.snapshotNodes()
+	assert.Equal(t, 1, len(nodes))
+
+	engine, err := c.reserve(nodes[0], withPort(), "first", "first-id", generations[e.ID])
+	assert.NoError(t, err)
+	assert.Equal(t, e, engine)
+
+	// The second one is computed again, even if it doesn't conflict.
+	_, err = c.reserve(nodes[0], withPort(), "second", "second-id", generations[e.ID])
+	assert.Equal(t, errStaleSnapshot, err)
+	noPort := cluster.BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{}, networktypes.NetworkingConfig{})
+	_, err = c.reserve(nodes[0], noPort, "third", "third-id", generations[e.ID])
+	assert.Equal(t, errStaleSnapshot, err)
+	_, _, err = c.placeContainer(noPort, "third", "third-id")
+	assert.NoError(t, err)
+
+	// The name of a pending container cannot be reused.
+	_, _, err = c.placeContainer(noPort, "third", "fourth-id")
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "Conflict"))
+
+	// A fresh placement sees the pending containers.
+	_, _, err = c.placeContainer(withPort(), "fifth", "fifth-id")
+	assert.Error(t, err)
+	assert.Equal(t, 2, len(c.pendingContainers))
+}
+
+func TestPlaceContainerConcurrentSpread(t *testing.T) {
+	strat, err := strategy.New("spread")
+	assert.Nil(t, err)
+
+	c := &Cluster{
+		engines:           make(map[string]*cluster.Engine),
+		scheduler:         scheduler.New(strat, nil),
+		pendingContainers: make(map[string]*pendingContainer),
+		generations:       make(map[string]uint64),
+	}
+	const engines, perEngine = 4, 3
+	for i := 0; i < engines; i++ {
+		e := createEngine(t, fmt.Sprintf("engine-%d", i))
+		c.engines[e.ID] = e
+	}
+
+	// Placements computed concurrently, from the same snapshots, are spread
+	// across the engines rather than piled on the first one.
+	var wg sync.WaitGroup
+	for i := 0; i < engines*perEngine; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			config := cluster.BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{}, networktypes.NetworkingConfig{})
+			_, _, err := c.placeContainer(config, fmt.Sprintf("container-%d", i), fmt.Sprintf("container-%d-id", i))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	placed := make(map[string]int)
+	for _, pc := range c.pendingContainers {
+		placed[pc.Engine.ID]++
+	}
+	assert.Equal(t, engines, len(placed))
+	for _, count := range placed {
+		assert.Equal(t, perEngine, count)
+	}
+}
+
+func TestPlaceContainerPortRange(t *testing.T) {
+	strat, err := strategy.New("spread")
+	assert.Nil(t, err)
+	filters, err := filter.New([]string{"port"})
+	assert.Nil(t, err)
+
+	c := &Cluster{
+		engines:           make(map[string]*cluster.Engine),
+		scheduler:         scheduler.New(strat, filters),
+		pendingContainers: make(map[string]*pendingContainer),
+		generations:       make(map[string]uint64),
+	}
+	newContainer := func(ID string, ports ...types.Port) *cluster.Container {
+		return &cluster.Container{
+			Container: types.Container{ID: ID, Ports: ports},
+			Config:    cluster.BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{}, networktypes.NetworkingConfig{}),
+		}
+	}
+
+	// Each engine has a single port to allocate. The spread strategy prefers
+	// engine-0, but its port is taken.
+	e0 := createEngine(t, "engine-0", newContainer("c0", types.Port{PrivatePort: 80, PublicPort: 30000, Type: "tcp"}))
+	e0.Labels = map[string]string{portRangeLabel: "30000-30000"}
+	e1 := createEngine(t, "engine-1", newContainer("c1"), newContainer("c2"))
+	e1.Labels = map[string]string{portRangeLabel: "30001-30001"}
+	c.engines[e0.ID] = e0
+	c.engines[e1.ID] = e1
+
+	withAllocatedPort := func() *cluster.ContainerConfig {
+		return cluster.BuildContainerConfig(containertypes.Config{
+			Labels: map[string]string{"com.docker.swarm.allocate-ports": "80"},
+		}, containertypes.HostConfig{}, networktypes.NetworkingConfig{})
+	}
+
+	// The engine whose port is taken is skipped.
+	config := withAllocatedPort()
+	_, engine, err := c.placeContainer(config, "first", "first-id")
+	assert.NoError(t, err)
+	assert.Equal(t, e1, engine)
+	assert.Equal(t, nat.PortMap{"80/tcp": {{HostPort: "30001"}}}, config.HostConfig.PortBindings)
+
+	// Once both are exhausted, the placement fails.
+	_, _, err = c.placeContainer(withAllocatedPort(), "second", "second-id")
+	assert.Equal(t, errNoFreePort, err)
+}
+
+func TestCheckContainerUpdatePolicy(t *testing.T) {
+	f, err := ioutil.TempFile("", "swarm-policy")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`{"Rules": [{"Name": "limits", "MaxMemory": "1g"}]}`)
+	assert.NoError(t, err)
+	f.Close()
+
+	policy, err := cluster.NewPolicyFile(f.Name())
+	assert.NoError(t, err)
+	c := &Cluster{policy: policy}
+
+	container := &cluster.Container{
+		Container: types.Container{ID: "container-id"},
+		Config: cluster.BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{
+			Resources: containertypes.Resources{Memory: 512 * 1024 * 1024},
+		}, networktypes.NetworkingConfig{}),
+	}
+
+	assert.NoError(t, c.CheckContainerUpdate(container, containertypes.UpdateConfig{Resources: containertypes.Resources{Memory: 1024 * 1024 * 1024}}))
+	err = c.CheckContainerUpdate(container, containertypes.UpdateConfig{Resources: containertypes.Resources{Memory: 2 * 1024 * 1024 * 1024}})
+	assert.IsType(t, &cluster.PolicyError{}, err)
+	// The container itself is left alone.
+	assert.Equal(t, int64(512*1024*1024), container.Config.HostConfig.Memory)
+}
+
+func TestCheckContainerUpdateLimits(t *testing.T) {
+	limits, err := cluster.ParseResourceLimits([]byte(`{"MaxMemory": "1g", "MaxCpus": 2}`))
+	assert.NoError(t, err)
+	c := &Cluster{limits: limits}
+
+	container := &cluster.Container{
+		Container: types.Container{ID: "container-id"},
+		Config: cluster.BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{
+			Resources: containertypes.Resources{Memory: 512 * 1024 * 1024, NanoCPUs: 1e9},
+		}, networktypes.NetworkingConfig{}),
+	}
+
+	assert.NoError(t, c.CheckContainerUpdate(container, containertypes.UpdateConfig{Resources: containertypes.Resources{Memory: 1024 * 1024 * 1024}}))
+	assert.Error(t, c.CheckContainerUpdate(container, containertypes.UpdateConfig{Resources: containertypes.Resources{Memory: 64 * 1024 * 1024 * 1024}}))
+	assert.Error(t, c.CheckContainerUpdate(container, containertypes.UpdateConfig{Resources: containertypes.Resources{NanoCPUs: 4e9}}))
 }
 
 // getOSTypeConstraint is a helper function that retrieves and returns the
@@ -419,8 +657,14 @@ func getOSTypeConstraint(config *cluster.ContainerConfig) (string, bool) {
 // mockClientWithInit creates a mock engine API client with the necessary
 // methods for initializing the connection already filled in
 func mockClientWithInit() *engineapimock.MockClient {
+	return mockClientWithInfo(mockInfo)
+}
+
+// mockClientWithInfo creates a mock engine API client initializing the
+// connection with the given info
+func mockClientWithInfo(info types.Info) *engineapimock.MockClient {
 	apiClient := engineapimock.NewMockClient()
-	apiClient.On("Info", mock.Anything).Return(mockInfo, nil)
+	apiClient.On("Info", mock.Anything).Return(info, nil)
 	apiClient.On("ServerVersion", mock.Anything).Return(mockVersion, nil)
 	apiClient.On("NetworkList", mock.Anything,
 		mock.AnythingOfType("NetworkListOptions"),
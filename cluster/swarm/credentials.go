@@ -0,0 +1,98 @@
+package swarm
+
+import (
+	"errors"
+	"path"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/discovery"
+	"github.com/docker/libkv/store"
+	"github.com/docker/swarm/cluster"
+	log "github.com/sirupsen/logrus"
+)
+
+// credentialsPath is the key of the registry credentials in the key-value
+// store of the discovery.
+const credentialsPath = "docker/swarm/credentials"
+
+// kvDiscovery is implemented by the discoveries backed by a key-value store.
+type kvDiscovery interface {
+	Store() store.Store
+	Prefix() string
+}
+
+var errCredentialsDisabled = errors.New("the registry credential store is disabled, set the swarm.credentials cluster option to enable it")
+
+// newCredentialStore opens the registry credential store configured by the
+// swarm.credentials and swarm.credentials.key options, if any. The store is
+// a file, or the key-value store of the discovery when set to "kv".
+func newCredentialStore(options cluster.DriverOpts, d discovery.Backend) *cluster.CredentialStore {
+	val, ok := options.String("swarm.credentials", "")
+	if !ok || val == "" {
+		return nil
+	}
+
+	var (
+		backend cluster.Backend
+		keyPath = val + ".key"
+	)
+	if val == "kv" {
+		kv, ok := d.(kvDiscovery)
+		if !ok {
+			log.Fatal("swarm.credentials=kv is only supported with consul, etcd and zookeeper discovery")
+		}
+		backend = cluster.NewKVBackend(kv.Store(), path.Join(kv.Prefix(), credentialsPath))
+		keyPath = ""
+	} else {
+		backend = cluster.NewFileBackend(val)
+	}
+	if key, ok := options.String("swarm.credentials.key", ""); ok && key != "" {
+		keyPath = key
+	}
+	if keyPath == "" {
+		log.Fatal("swarm.credentials.key is required to store the credentials in the discovery")
+	}
+
+	// The managers sharing the credentials of the discovery must share the
+	// key as well, it has to be distributed to each of them.
+	key, err := cluster.LoadCredentialKey(keyPath, val != "kv")
+	if err != nil {
+		log.Fatalf("swarm.credentials.key: %v", err)
+	}
+	credentials, err := cluster.NewCredentialStore(backend, key)
+	if err != nil {
+		log.Fatalf("swarm.credentials: unable to open %s: %v", val, err)
+	}
+	return credentials
+}
+
+// SetRegistryCredentials stores the credentials of a registry. nil
+// credentials remove them.
+func (c *Cluster) SetRegistryCredentials(registry string, auth *types.AuthConfig) error {
+	if c.credentials == nil {
+		return errCredentialsDisabled
+	}
+	return c.credentials.Set(registry, auth)
+}
+
+// RegistryCredentials returns the registries having credentials.
+func (c *Cluster) RegistryCredentials() ([]string, error) {
+	if c.credentials == nil {
+		return nil, errCredentialsDisabled
+	}
+	return c.credentials.Registries()
+}
+
+// registryAuth returns the credentials of the client if it sent any, or
+// else the stored credentials of the registry of image.
+func (c *Cluster) registryAuth(image string, authConfig *types.AuthConfig) *types.AuthConfig {
+	if c.credentials == nil {
+		return authConfig
+	}
+	auth, err := c.credentials.Lookup(image, authConfig)
+	if err != nil {
+		log.WithFields(log.Fields{"image": image}).WithError(err).Warn("Unable to look up registry credentials")
+		return authConfig
+	}
+	return auth
+}
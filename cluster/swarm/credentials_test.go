@@ -0,0 +1,42 @@
+package swarm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/swarm/cluster"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryCredentials(t *testing.T) {
+	c := &Cluster{}
+
+	// The credential store is disabled by default.
+	assert.Error(t, c.SetRegistryCredentials("docker.io", &types.AuthConfig{Username: "hub"}))
+	_, err := c.RegistryCredentials()
+	assert.Error(t, err)
+	assert.Nil(t, c.registryAuth("busybox", nil))
+
+	dir, err := ioutil.TempDir("", "swarm-credentials")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	c.credentials = newCredentialStore(cluster.DriverOpts{"swarm.credentials=" + filepath.Join(dir, "credentials")}, nil)
+	assert.NotNil(t, c.credentials)
+	_, err = os.Stat(filepath.Join(dir, "credentials.key"))
+	assert.NoError(t, err)
+
+	hub := &types.AuthConfig{Username: "hub", Password: "s3cret"}
+	assert.NoError(t, c.SetRegistryCredentials("docker.io", hub))
+	registries, err := c.RegistryCredentials()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"docker.io"}, registries)
+
+	// Rescheduled containers are created without client credentials.
+	assert.Equal(t, hub, c.registryAuth("busybox:latest", nil))
+	client := &types.AuthConfig{Username: "alice"}
+	assert.Equal(t, client, c.registryAuth("busybox:latest", client))
+	assert.Nil(t, c.registryAuth("registry.example.com/app", nil))
+}
@@ -0,0 +1,106 @@
+package swarm
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/swarm/cluster"
+)
+
+// convertLinks replaces the links to containers publishing ports with host
+// entries pointing to the node of the linked container and the environment
+// variables legacy links would set, so that the container doesn't need to be
+// co-located with them. Links to containers without published ports are
+// left alone.
+func (c *Cluster) convertLinks(config *cluster.ContainerConfig, name string) {
+	links := []string{}
+	for _, link := range config.HostConfig.Links {
+		parts := strings.SplitN(link, ":", 2)
+		alias := parts[0]
+		if len(parts) == 2 {
+			alias = parts[1]
+		}
+
+		target := c.Container(parts[0])
+		if target == nil || target.Engine == nil || len(publishedPorts(target)) == 0 {
+			links = append(links, link)
+			continue
+		}
+
+		ip := target.Engine.IP
+		config.HostConfig.ExtraHosts = append(config.HostConfig.ExtraHosts, alias+":"+ip)
+		config.Env = append(config.Env, linkEnv(name, alias, ip, publishedPorts(target))...)
+	}
+	config.HostConfig.Links = links
+}
+
+// publishedPorts returns the ports published on the host by a container and
+// reachable from other nodes, sorted by private port. A port published on
+// several addresses, such as 0.0.0.0 and ::, is only returned once.
+func publishedPorts(container *cluster.Container) []types.Port {
+	ports := []types.Port{}
+	for _, port := range container.Ports {
+		if port.PublicPort == 0 {
+			continue
+		}
+		if ip := net.ParseIP(port.IP); ip != nil && ip.IsLoopback() {
+			continue
+		}
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].PrivatePort != ports[j].PrivatePort {
+			return ports[i].PrivatePort < ports[j].PrivatePort
+		}
+		if ports[i].Type != ports[j].Type {
+			return ports[i].Type < ports[j].Type
+		}
+		return ports[i].IP < ports[j].IP
+	})
+
+	unique := []types.Port{}
+	for i, port := range ports {
+		if i > 0 && port.PrivatePort == ports[i-1].PrivatePort && port.Type == ports[i-1].Type {
+			continue
+		}
+		unique = append(unique, port)
+	}
+	return unique
+}
+
+// linkEnv returns the environment variables a legacy link with the given
+// alias would set, pointing to the ports published on the host.
+func linkEnv(name, alias, defaultIP string, ports []types.Port) []string {
+	prefix := strings.Replace(strings.ToUpper(alias), "-", "_", -1)
+	env := []string{}
+	if name != "" {
+		env = append(env, fmt.Sprintf("%s_NAME=/%s/%s", prefix, strings.TrimPrefix(name, "/"), alias))
+	}
+
+	for i, port := range ports {
+		ip := port.IP
+		if ip == "" || ip == "0.0.0.0" || ip == "::" {
+			ip = defaultIP
+		}
+		proto := strings.ToLower(port.Type)
+		if proto == "" {
+			proto = "tcp"
+		}
+		url := fmt.Sprintf("%s://%s:%d", proto, ip, port.PublicPort)
+		portPrefix := fmt.Sprintf("%s_PORT_%d_%s", prefix, port.PrivatePort, strings.ToUpper(proto))
+
+		if i == 0 {
+			env = append(env, fmt.Sprintf("%s_PORT=%s", prefix, url))
+		}
+		env = append(env,
+			fmt.Sprintf("%s=%s", portPrefix, url),
+			fmt.Sprintf("%s_ADDR=%s", portPrefix, ip),
+			fmt.Sprintf("%s_PORT=%d", portPrefix, port.PublicPort),
+			fmt.Sprintf("%s_PROTO=%s", portPrefix, proto),
+		)
+	}
+	return env
+}
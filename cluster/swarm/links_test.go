@@ -0,0 +1,61 @@
+package swarm
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/docker/swarm/cluster"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertLinks(t *testing.T) {
+	c := &Cluster{
+		engines: make(map[string]*cluster.Engine),
+	}
+
+	db := &cluster.Container{
+		Container: types.Container{
+			ID:    "db-id",
+			Names: []string{"/db"},
+			Ports: []types.Port{
+				{PrivatePort: 5432, PublicPort: 32768, Type: "tcp", IP: "::"},
+				{PrivatePort: 5432, PublicPort: 32768, Type: "tcp", IP: "0.0.0.0"},
+				{PrivatePort: 8080, PublicPort: 32769, Type: "tcp", IP: "127.0.0.1"},
+				{PrivatePort: 8080, PublicPort: 32769, Type: "tcp", IP: "::1"},
+				{PrivatePort: 9187, Type: "tcp"},
+			},
+		},
+		Config: cluster.BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{}, networktypes.NetworkingConfig{}),
+	}
+	cache := &cluster.Container{
+		Container: types.Container{
+			ID:    "cache-id",
+			Names: []string{"/cache"},
+		},
+		Config: cluster.BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{}, networktypes.NetworkingConfig{}),
+	}
+	e := createEngine(t, "test-engine", db, cache)
+	e.IP = "10.0.0.1"
+	c.engines[e.ID] = e
+
+	config := cluster.BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{
+		Links: []string{"db:my-db", "cache:cache"},
+	}, networktypes.NetworkingConfig{})
+	c.convertLinks(config, "web")
+
+	// The link to cache is kept since it doesn't publish any port. The port
+	// 5432 is published on both 0.0.0.0 and ::, and the port 8080 is only
+	// reachable from the node of db.
+	assert.Equal(t, config.HostConfig.Links, []string{"cache:cache"})
+	assert.Equal(t, config.HostConfig.ExtraHosts, []string{"my-db:10.0.0.1"})
+	assert.Equal(t, config.Env, []string{
+		"MY_DB_NAME=/web/my-db",
+		"MY_DB_PORT=tcp://10.0.0.1:32768",
+		"MY_DB_PORT_5432_TCP=tcp://10.0.0.1:32768",
+		"MY_DB_PORT_5432_TCP_ADDR=10.0.0.1",
+		"MY_DB_PORT_5432_TCP_PORT=32768",
+		"MY_DB_PORT_5432_TCP_PROTO=tcp",
+	})
+}
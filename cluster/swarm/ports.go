@@ -0,0 +1,124 @@
+package swarm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+)
+
+// portRangeLabel is the engine label overriding the cluster port range.
+const portRangeLabel = "swarm.portrange"
+
+// errNoFreePort is returned when none of the nodes selected for a container
+// has enough free host ports left in its port range.
+var errNoFreePort = errors.New("unable to find a node with enough free host ports in its port range")
+
+// noFreePortError is returned when the port range of an engine is exhausted.
+type noFreePortError struct {
+	start, end int
+	engine     string
+}
+
+func (err noFreePortError) Error() string {
+	return fmt.Sprintf("no free host port in range %d-%d on node %s", err.start, err.end, err.engine)
+}
+
+// portRange is a range of host ports the manager allocates ports from.
+type portRange struct {
+	start, end int
+}
+
+func parsePortRange(value string) (*portRange, error) {
+	start, end, err := nat.ParsePortRangeToInt(value)
+	if err != nil || start == 0 {
+		return nil, fmt.Errorf("invalid port range: %s", value)
+	}
+	return &portRange{start: start, end: end}, nil
+}
+
+// enginePortRange returns the port range of an engine: its swarm.portrange
+// label if set, the cluster port range otherwise.
+func (c *Cluster) enginePortRange(engine *cluster.Engine) (*portRange, error) {
+	if value, ok := engine.Labels[portRangeLabel]; ok {
+		return parsePortRange(value)
+	}
+	if c.portRange == nil {
+		return nil, fmt.Errorf("no port range configured for engine %s, set the %s cluster option or engine label", engine.Name, portRangeLabel)
+	}
+	return c.portRange, nil
+}
+
+// allocatePortsLocked binds the ports the container asked to be allocated to
+// host ports of the engine port range that aren't used by the containers of
+// the engine, pending containers included. pendingMu must be held.
+func (c *Cluster) allocatePortsLocked(engine *cluster.Engine, config *cluster.ContainerConfig) error {
+	ports, err := config.AllocatePorts()
+	if err != nil || len(ports) == 0 {
+		return err
+	}
+
+	r, err := c.enginePortRange(engine)
+	if err != nil {
+		return err
+	}
+
+	used := usedHostPorts(c.newNodeLocked(engine))
+	if config.HostConfig.PortBindings == nil {
+		config.HostConfig.PortBindings = nat.PortMap{}
+	}
+	if config.ExposedPorts == nil {
+		config.ExposedPorts = nat.PortSet{}
+	}
+
+	next := r.start
+	for _, port := range ports {
+		for next <= r.end && used[next] {
+			next++
+		}
+		if next > r.end {
+			return noFreePortError{start: r.start, end: r.end, engine: engine.Name}
+		}
+		// Replace any previous allocation, which happens when the
+		// container gets rescheduled.
+		config.HostConfig.PortBindings[port] = []nat.PortBinding{{HostPort: strconv.Itoa(next)}}
+		config.ExposedPorts[port] = struct{}{}
+		used[next] = true
+	}
+	return nil
+}
+
+// usedHostPorts returns the host ports bound by the containers of a node.
+func usedHostPorts(n *node.Node) map[int]bool {
+	used := make(map[int]bool)
+	add := func(bindings nat.PortMap) {
+		for _, binding := range bindings {
+			for _, b := range binding {
+				if port, err := strconv.Atoi(b.HostPort); err == nil {
+					used[port] = true
+				}
+			}
+		}
+	}
+
+	for _, c := range n.Containers {
+		for _, port := range c.Ports {
+			if port.PublicPort != 0 {
+				used[int(port.PublicPort)] = true
+			}
+		}
+		if c.Info.ContainerJSONBase != nil && c.Info.HostConfig != nil {
+			add(c.Info.HostConfig.PortBindings)
+		}
+		if c.Info.NetworkSettings != nil {
+			add(c.Info.NetworkSettings.Ports)
+		}
+		if c.Config != nil {
+			add(c.Config.HostConfig.PortBindings)
+		}
+	}
+	return used
+}
@@ -0,0 +1,67 @@
+package swarm
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/swarm/cluster"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocatePorts(t *testing.T) {
+	c := &Cluster{
+		engines:           make(map[string]*cluster.Engine),
+		pendingContainers: make(map[string]*pendingContainer),
+		portRange:         &portRange{start: 30000, end: 30003},
+	}
+
+	running := &cluster.Container{
+		Container: types.Container{
+			ID:    "running-id",
+			Ports: []types.Port{{PrivatePort: 80, PublicPort: 30000, Type: "tcp"}},
+		},
+		Config: cluster.BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{}, networktypes.NetworkingConfig{}),
+	}
+	e := createEngine(t, "test-engine", running)
+	c.engines[e.ID] = e
+
+	// A pending container already got port 30001.
+	c.pendingContainers["pending"] = &pendingContainer{
+		Config: cluster.BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{
+			PortBindings: nat.PortMap{"80/tcp": {{HostPort: "30001"}}},
+		}, networktypes.NetworkingConfig{}),
+		Engine: e,
+	}
+
+	newConfig := func(ports string) *cluster.ContainerConfig {
+		return cluster.BuildContainerConfig(containertypes.Config{
+			Labels: map[string]string{"com.docker.swarm.allocate-ports": ports},
+		}, containertypes.HostConfig{}, networktypes.NetworkingConfig{})
+	}
+
+	config := newConfig("80,53/udp")
+	assert.NoError(t, c.allocatePortsLocked(e, config))
+	assert.Equal(t, config.HostConfig.PortBindings, nat.PortMap{
+		"80/tcp": {{HostPort: "30002"}},
+		"53/udp": {{HostPort: "30003"}},
+	})
+	assert.Contains(t, config.ExposedPorts, nat.Port("53/udp"))
+
+	// The range is exhausted once the allocation is pending.
+	c.pendingContainers["pending2"] = &pendingContainer{Config: config, Engine: e}
+	assert.Error(t, c.allocatePortsLocked(e, newConfig("8080")))
+
+	// The engine label overrides the cluster range.
+	e.Labels[portRangeLabel] = "40000-40010"
+	config = newConfig("8080")
+	assert.NoError(t, c.allocatePortsLocked(e, config))
+	assert.Equal(t, config.HostConfig.PortBindings, nat.PortMap{"8080/tcp": {{HostPort: "40000"}}})
+
+	// Containers not asking for allocated ports are left alone.
+	config = newConfig("")
+	assert.NoError(t, c.allocatePortsLocked(e, config))
+	assert.Empty(t, config.HostConfig.PortBindings)
+}
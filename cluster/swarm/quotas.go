@@ -0,0 +1,172 @@
+package swarm
+
+import (
+	"errors"
+	"path"
+	"sort"
+
+	"github.com/docker/docker/pkg/discovery"
+	"github.com/docker/swarm/cluster"
+	log "github.com/sirupsen/logrus"
+)
+
+// quotasPath is the key of the quotas in the key-value store of the
+// discovery.
+const quotasPath = "docker/swarm/quotas"
+
+var errQuotasDisabled = errors.New("quotas are disabled, set the swarm.quota.label cluster option to enable them")
+
+// newQuotaStore opens the quota store configured by the swarm.quota.store
+// option: a file, or the key-value store of the discovery when set to "kv".
+// Quotas are kept in memory by default.
+func newQuotaStore(options cluster.DriverOpts, d discovery.Backend) *cluster.QuotaStore {
+	backend := cluster.NewMemoryBackend()
+	val, ok := options.String("swarm.quota.store", "")
+	if ok && val == "kv" {
+		kv, ok := d.(kvDiscovery)
+		if !ok {
+			log.Fatal("swarm.quota.store=kv is only supported with consul, etcd and zookeeper discovery")
+		}
+		backend = cluster.NewKVBackend(kv.Store(), path.Join(kv.Prefix(), quotasPath))
+	} else if ok && val != "" {
+		backend = cluster.NewFileBackend(val)
+	}
+
+	quotas, err := cluster.NewQuotaStore(backend)
+	if err != nil {
+		log.Fatalf("swarm.quota.store: unable to open %s: %v", val, err)
+	}
+	return quotas
+}
+
+// refreshQuotas loads the quotas from the quota store, which other managers
+// may have updated. The last quotas loaded are kept if the store can't be
+// read. pendingMu must not be held.
+func (c *Cluster) refreshQuotas() {
+	if c.quotaStore == nil {
+		return
+	}
+	quotas, err := c.quotaStore.Quotas()
+	if err != nil {
+		log.WithError(err).Warn("Unable to load the quotas")
+		return
+	}
+
+	c.pendingMu.Lock()
+	c.quotas = quotas
+	c.pendingMu.Unlock()
+}
+
+// SetQuota sets the quota of a tenant. A nil quota removes it.
+func (c *Cluster) SetQuota(tenant string, quota *cluster.Quota) error {
+	if c.quotaLabel == "" {
+		return errQuotasDisabled
+	}
+
+	if err := c.quotaStore.Set(tenant, quota); err != nil {
+		return err
+	}
+	c.refreshQuotas()
+	return nil
+}
+
+// Quotas returns the usage of the tenants having a quota or containers.
+func (c *Cluster) Quotas() ([]cluster.QuotaUsage, error) {
+	if c.quotaLabel == "" {
+		return nil, errQuotasDisabled
+	}
+
+	c.refreshQuotas()
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	usage := make(map[string]*cluster.QuotaUsage)
+	get := func(tenant string) *cluster.QuotaUsage {
+		u, ok := usage[tenant]
+		if !ok {
+			u = &cluster.QuotaUsage{Tenant: tenant}
+			usage[tenant] = u
+		}
+		return u
+	}
+	for tenant, quota := range c.quotas {
+		q := *quota
+		get(tenant).Quota = &q
+	}
+	for _, config := range c.tenantContainersLocked("", "") {
+		get(config.Labels[c.quotaLabel]).Used.Add(config)
+	}
+
+	out := []cluster.QuotaUsage{}
+	for _, u := range usage {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tenant < out[j].Tenant })
+	return out, nil
+}
+
+// tenantContainersLocked returns the configurations of the containers of a
+// tenant, or of all the tenants if tenant is empty, pending containers
+// included. The container with the Swarm ID skipID, or with the ID skipID if
+// it has no Swarm ID, is skipped. pendingMu must be held.
+func (c *Cluster) tenantContainersLocked(tenant, skipID string) []*cluster.ContainerConfig {
+	label := c.quotaLabel
+	if tenant != "" {
+		label += "=" + tenant
+	}
+
+	configs := []*cluster.ContainerConfig{}
+	seen := make(map[string]bool)
+	for _, container := range c.LookupContainers(nil, []string{label}) {
+		if container.Config == nil {
+			continue
+		}
+		if value, ok := container.Config.Labels[c.quotaLabel]; !ok || (tenant != "" && value != tenant) {
+			continue
+		}
+		swarmID := container.Config.SwarmID()
+		if swarmID == "" {
+			if skipID != "" && container.ID == skipID {
+				continue
+			}
+		} else {
+			if swarmID == skipID || seen[swarmID] {
+				continue
+			}
+			seen[swarmID] = true
+		}
+		configs = append(configs, container.Config)
+	}
+	for swarmID, pending := range c.pendingContainers {
+		if swarmID == skipID || seen[swarmID] {
+			continue
+		}
+		if value, ok := pending.Config.Labels[c.quotaLabel]; !ok || (tenant != "" && value != tenant) {
+			continue
+		}
+		configs = append(configs, pending.Config)
+	}
+	return configs
+}
+
+// checkQuotaLocked returns a *cluster.QuotaError if adding a container with
+// config, replacing the container identified by skipID if any (see
+// tenantContainersLocked), would exceed the quota of its tenant. pendingMu
+// must be held.
+func (c *Cluster) checkQuotaLocked(config *cluster.ContainerConfig, skipID string) error {
+	if c.quotaLabel == "" {
+		return nil
+	}
+	tenant := config.Labels[c.quotaLabel]
+	quota, ok := c.quotas[tenant]
+	if tenant == "" || !ok {
+		return nil
+	}
+
+	used := cluster.Quota{}
+	for _, other := range c.tenantContainersLocked(tenant, skipID) {
+		used.Add(other)
+	}
+	used.Add(config)
+	return quota.Check(tenant, used)
+}
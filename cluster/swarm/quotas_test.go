@@ -0,0 +1,116 @@
+package swarm
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/docker/swarm/cluster"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotas(t *testing.T) {
+	store, err := cluster.NewQuotaStore(cluster.NewMemoryBackend())
+	assert.NoError(t, err)
+	c := &Cluster{
+		engines:           make(map[string]*cluster.Engine),
+		pendingContainers: make(map[string]*pendingContainer),
+		quotaStore:        store,
+	}
+
+	newConfig := func(team, swarmID string, memory int64) *cluster.ContainerConfig {
+		labels := map[string]string{"com.docker.swarm.id": swarmID}
+		if team != "" {
+			labels["team"] = team
+		}
+		return cluster.BuildContainerConfig(containertypes.Config{Labels: labels}, containertypes.HostConfig{
+			Resources: containertypes.Resources{Memory: memory, CPUShares: 1},
+		}, networktypes.NetworkingConfig{})
+	}
+
+	running := &cluster.Container{
+		Container: types.Container{ID: "running-id"},
+		Config:    newConfig("web", "running-swarm-id", 1024),
+	}
+	other := &cluster.Container{
+		Container: types.Container{ID: "other-id"},
+		Config:    newConfig("batch", "other-swarm-id", 4096),
+	}
+	// Created directly on the engine, without Swarm ID.
+	unmanaged := &cluster.Container{
+		Container: types.Container{ID: "unmanaged-id"},
+		Config:    newConfig("batch", "", 1024),
+	}
+	delete(unmanaged.Config.Labels, "com.docker.swarm.id")
+	e := createEngine(t, "test-engine", running, other, unmanaged)
+	c.engines[e.ID] = e
+	c.pendingContainers["pending-swarm-id"] = &pendingContainer{Config: newConfig("web", "pending-swarm-id", 1024), Engine: e}
+
+	// Quotas are disabled without a tenant label.
+	assert.Error(t, c.SetQuota("web", &cluster.Quota{Containers: 2}))
+	_, err = c.Quotas()
+	assert.Error(t, err)
+	assert.NoError(t, c.checkQuotaLocked(newConfig("web", "new-swarm-id", 1024), "new-swarm-id"))
+
+	c.quotaLabel = "team"
+	assert.NoError(t, c.SetQuota("web", &cluster.Quota{Containers: 2, Memory: 4096}))
+
+	usage, err := c.Quotas()
+	assert.NoError(t, err)
+	assert.Equal(t, []cluster.QuotaUsage{
+		{Tenant: "batch", Used: cluster.Quota{Containers: 2, Memory: 5120, Cpus: 2}},
+		{Tenant: "web", Quota: &cluster.Quota{Containers: 2, Memory: 4096}, Used: cluster.Quota{Containers: 2, Memory: 2048, Cpus: 2}},
+	}, usage)
+
+	// The running and pending containers use the whole container quota.
+	err = c.checkQuotaLocked(newConfig("web", "new-swarm-id", 1024), "new-swarm-id")
+	assert.IsType(t, &cluster.QuotaError{}, err)
+
+	// Rescheduled containers replace their previous instance.
+	assert.NoError(t, c.checkQuotaLocked(newConfig("web", "running-swarm-id", 1024), "running-swarm-id"))
+
+	// Other tenants and containers without tenant are not limited.
+	assert.NoError(t, c.checkQuotaLocked(newConfig("batch", "new-swarm-id", 8192), "new-swarm-id"))
+	assert.NoError(t, c.checkQuotaLocked(newConfig("", "new-swarm-id", 8192), "new-swarm-id"))
+
+	// Updates are checked against the memory quota.
+	assert.NoError(t, c.CheckContainerUpdate(running, containertypes.UpdateConfig{Resources: containertypes.Resources{Memory: 3072}}))
+	err = c.CheckContainerUpdate(running, containertypes.UpdateConfig{Resources: containertypes.Resources{Memory: 4096}})
+	assert.IsType(t, &cluster.QuotaError{}, err)
+
+	// Containers without Swarm ID are replaced by their update too.
+	assert.NoError(t, c.SetQuota("batch", &cluster.Quota{Memory: 6144}))
+	assert.NoError(t, c.CheckContainerUpdate(unmanaged, containertypes.UpdateConfig{Resources: containertypes.Resources{Memory: 2048}}))
+
+	assert.NoError(t, c.SetQuota("web", nil))
+	assert.NoError(t, c.checkQuotaLocked(newConfig("web", "new-swarm-id", 1024), "new-swarm-id"))
+}
+
+func TestQuotasNamespaceLabel(t *testing.T) {
+	store, err := cluster.NewQuotaStore(cluster.NewMemoryBackend())
+	assert.NoError(t, err)
+	// The API labels the containers of confined clients with their
+	// namespace, which they can't leave out.
+	c := &Cluster{
+		engines:           make(map[string]*cluster.Engine),
+		pendingContainers: make(map[string]*pendingContainer),
+		quotaStore:        store,
+		quotaLabel:        cluster.SwarmLabelNamespace + ".namespace",
+	}
+	newConfig := func(swarmID string) *cluster.ContainerConfig {
+		return cluster.BuildContainerConfig(containertypes.Config{Labels: map[string]string{
+			"com.docker.swarm.id":        swarmID,
+			"com.docker.swarm.namespace": "web",
+		}}, containertypes.HostConfig{}, networktypes.NetworkingConfig{})
+	}
+	e := createEngine(t, "test-engine", &cluster.Container{
+		Container: types.Container{ID: "running-id"},
+		Config:    newConfig("running-swarm-id"),
+	})
+	c.engines[e.ID] = e
+
+	assert.NoError(t, c.SetQuota("web", &cluster.Quota{Containers: 1}))
+	err = c.checkQuotaLocked(newConfig("new-swarm-id"), "new-swarm-id")
+	assert.IsType(t, &cluster.QuotaError{}, err)
+}
@@ -9,6 +9,14 @@ type Volume struct {
 	Engine *Engine
 }
 
+// IsLocal returns true if the volume only exists on its engine.
+func (volume *Volume) IsLocal() bool {
+	if volume.Scope != "" {
+		return volume.Scope == "local"
+	}
+	return volume.Driver == "local"
+}
+
 // Volumes represents an array of volumes
 type Volumes []*Volume
 
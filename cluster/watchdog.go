@@ -142,6 +142,8 @@ func (w *Watchdog) rescheduleContainers(e *Engine) {
 			endpointsConfig[k] = v
 		}
 		c.Config.NetworkingConfig.EndpointsConfig = endpointsConfig
+		// There are no client credentials to pull the image with, the
+		// cluster falls back to its registry credential store, if any.
 		newContainer, err := w.cluster.CreateContainer(c.Config, c.Info.Name, nil)
 		if err != nil {
 			log.Errorf("Failed to reschedule container %s: %v", c.ID, err)
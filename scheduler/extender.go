@@ -0,0 +1,193 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultExtenderTimeout = 5 * time.Second
+
+// Extender is an HTTP endpoint called after the built-in filters, which may
+// filter and score the candidate nodes.
+type Extender struct {
+	URL      string
+	Timeout  time.Duration
+	FailOpen bool
+
+	client *http.Client
+}
+
+// ExtenderNode is the description of a candidate node sent to extenders.
+type ExtenderNode struct {
+	ID          string
+	Name        string
+	Addr        string
+	Labels      map[string]string
+	TotalMemory int64
+	UsedMemory  int64
+	TotalCpus   int64
+	UsedCpus    int64
+}
+
+// ExtenderRequest is the body of the requests sent to extenders.
+type ExtenderRequest struct {
+	Config *cluster.ContainerConfig
+	Nodes  []ExtenderNode
+}
+
+// ExtenderResponse is the body of the responses of extenders. Nodes, when
+// set, lists the IDs of the nodes to keep. Scores maps node IDs to a score,
+// nodes with higher scores being preferred.
+type ExtenderResponse struct {
+	Nodes  []string
+	Scores map[string]float64
+}
+
+// ParseExtender parses an extender definition, expressed as a comma separated
+// list of key=value pairs (ex. url=http://localhost:8080/filter,timeout=2s,failopen=true).
+func ParseExtender(definition string) (*Extender, error) {
+	extender := &Extender{Timeout: defaultExtenderTimeout}
+	for _, option := range strings.Split(definition, ",") {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("invalid extender option %q in %q", option, definition)
+		}
+		switch kv[0] {
+		case "url":
+			extender.URL = kv[1]
+		case "timeout":
+			timeout, err := time.ParseDuration(kv[1])
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("invalid extender timeout %q", kv[1])
+			}
+			extender.Timeout = timeout
+		case "failopen":
+			failOpen, err := strconv.ParseBool(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid extender failopen %q", kv[1])
+			}
+			extender.FailOpen = failOpen
+		default:
+			return nil, fmt.Errorf("unsupported extender option %q", kv[0])
+		}
+	}
+
+	if extender.URL == "" {
+		return nil, fmt.Errorf("missing extender url in %q", definition)
+	}
+	return extender, nil
+}
+
+// call sends the container configuration and the candidate nodes to the
+// extender.
+func (e *Extender) call(config *cluster.ContainerConfig, nodes []*node.Node) (*ExtenderResponse, error) {
+	request := ExtenderRequest{Config: config, Nodes: make([]ExtenderNode, 0, len(nodes))}
+	for _, n := range nodes {
+		request.Nodes = append(request.Nodes, ExtenderNode{
+			ID:          n.ID,
+			Name:        n.Name,
+			Addr:        n.Addr,
+			Labels:      n.Labels,
+			TotalMemory: n.TotalMemory,
+			UsedMemory:  n.UsedMemory,
+			TotalCpus:   n.TotalCpus,
+			UsedCpus:    n.UsedCpus,
+		})
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.client.Post(e.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	response := &ExtenderResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	return response, nil
+}
+
+// applyExtenders filters the candidate nodes through the extenders and
+// returns the accumulated score of each remaining node.
+func applyExtenders(extenders []*Extender, config *cluster.ContainerConfig, nodes []*node.Node) ([]*node.Node, map[string]float64, error) {
+	scores := make(map[string]float64)
+	for _, extender := range extenders {
+		response, err := extender.call(config, nodes)
+		if err != nil {
+			if extender.FailOpen {
+				log.WithFields(log.Fields{"url": extender.URL, "error": err}).Warn("Ignoring failed scheduler extender")
+				continue
+			}
+			return nil, nil, fmt.Errorf("scheduler extender %s failed: %v", extender.URL, err)
+		}
+
+		if response.Nodes != nil {
+			keep := make(map[string]bool, len(response.Nodes))
+			for _, ID := range response.Nodes {
+				keep[ID] = true
+			}
+			candidates := []*node.Node{}
+			for _, n := range nodes {
+				if keep[n.ID] {
+					candidates = append(candidates, n)
+				}
+			}
+			nodes = candidates
+		}
+		for ID, score := range response.Scores {
+			scores[ID] += score
+		}
+	}
+	return nodes, scores, nil
+}
+
+// ExtenderDecision holds the nodes accepted by the extenders for a container,
+// and their scores.
+type ExtenderDecision struct {
+	nodes  map[string]bool
+	scores map[string]float64
+}
+
+// filter returns the nodes accepted by the extenders. A nil decision accepts
+// all the nodes.
+func (d *ExtenderDecision) filter(nodes []*node.Node) []*node.Node {
+	if d == nil {
+		return nodes
+	}
+	accepted := []*node.Node{}
+	for _, n := range nodes {
+		if d.nodes[n.ID] {
+			accepted = append(accepted, n)
+		}
+	}
+	return accepted
+}
+
+// sort orders nodes by decreasing extender score, keeping the order of the
+// strategy between nodes with the same score.
+func (d *ExtenderDecision) sort(nodes []*node.Node) {
+	if d == nil || len(d.scores) == 0 {
+		return
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return d.scores[nodes[i].ID] > d.scores[nodes[j].ID]
+	})
+}
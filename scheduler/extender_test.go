@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	containertypes "github.com/docker/docker/api/types/container"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/filter"
+	"github.com/docker/swarm/scheduler/node"
+	"github.com/docker/swarm/scheduler/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectNodesForContainerExtenders(t *testing.T) {
+	var (
+		nodes = []*node.Node{
+			{ID: "node-0-id", Name: "node-0-name", Addr: "node-0", TotalMemory: 1024, TotalCpus: 1},
+			{ID: "node-1-id", Name: "node-1-name", Addr: "node-1", TotalMemory: 1024, TotalCpus: 1, Labels: map[string]string{"license": "yes"}},
+			{ID: "node-2-id", Name: "node-2-name", Addr: "node-2", TotalMemory: 1024, TotalCpus: 1, Labels: map[string]string{"license": "yes"}},
+		}
+		config = cluster.BuildContainerConfig(containertypes.Config{}, containertypes.HostConfig{}, networktypes.NetworkingConfig{})
+	)
+
+	// Only keeps the licensed nodes.
+	filtering := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := ExtenderRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.NotNil(t, request.Config)
+		response := ExtenderResponse{Nodes: []string{}}
+		for _, n := range request.Nodes {
+			if n.Labels["license"] == "yes" {
+				response.Nodes = append(response.Nodes, n.ID)
+			}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer filtering.Close()
+
+	// Prefers the cheapest node.
+	scoring := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ExtenderResponse{Scores: map[string]float64{"node-2-id": 10}})
+	}))
+	defer scoring.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer failing.Close()
+
+	s := New(&strategy.SpreadPlacementStrategy{}, []filter.Filter{&filter.ConstraintFilter{}})
+	s.AddExtender(&Extender{URL: filtering.URL, Timeout: time.Second})
+	s.AddExtender(&Extender{URL: scoring.URL, Timeout: time.Second})
+
+	decision, err := s.CallExtenders(nodes, config)
+	assert.NoError(t, err)
+	candidates, err := s.SelectNodesWithExtenders(nodes, config, decision)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(candidates))
+	assert.Equal(t, "node-2-id", candidates[0].ID)
+	assert.Equal(t, "node-1-id", candidates[1].ID)
+
+	// The decision holds for the following attempts, without calling the
+	// extenders again.
+	filtering.Close()
+	candidates, err = s.SelectNodesWithExtenders(nodes[1:], config, decision)
+	assert.NoError(t, err)
+	assert.Equal(t, "node-2-id", candidates[0].ID)
+
+	// Selecting nodes for other purposes doesn't call the extenders.
+	candidates, err = s.SelectNodesForContainer(nodes, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(candidates))
+
+	s = New(&strategy.SpreadPlacementStrategy{}, []filter.Filter{&filter.ConstraintFilter{}})
+	s.AddExtender(&Extender{URL: scoring.URL, Timeout: time.Second})
+
+	// Failing extenders are ignored when failing open.
+	s.AddExtender(&Extender{URL: failing.URL, Timeout: 10 * time.Millisecond, FailOpen: true})
+	decision, err = s.CallExtenders(nodes, config)
+	assert.NoError(t, err)
+	candidates, err = s.SelectNodesWithExtenders(nodes, config, decision)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(candidates))
+
+	// And abort the placement otherwise.
+	s.AddExtender(&Extender{URL: failing.URL, Timeout: 10 * time.Millisecond})
+	_, err = s.CallExtenders(nodes, config)
+	assert.Error(t, err)
+}
+
+func TestParseExtender(t *testing.T) {
+	extender, err := ParseExtender("url=http://localhost:8080/filter")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/filter", extender.URL)
+	assert.Equal(t, defaultExtenderTimeout, extender.Timeout)
+	assert.False(t, extender.FailOpen)
+
+	extender, err = ParseExtender("url=http://localhost:8080/filter,timeout=2s,failopen=true")
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, extender.Timeout)
+	assert.True(t, extender.FailOpen)
+
+	for _, definition := range []string{
+		"",
+		"timeout=2s",
+		"url=http://localhost,timeout=2",
+		"url=http://localhost,failopen=maybe",
+		"url=http://localhost,weight=2",
+	} {
+		_, err := ParseExtender(definition)
+		assert.Error(t, err, definition)
+	}
+}
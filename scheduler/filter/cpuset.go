@@ -0,0 +1,67 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+)
+
+// CpusetFilter guarantees that, when scheduling a container pinned to a set
+// of CPUs, only nodes where none of those CPUs are already pinned by another
+// container will be considered.
+type CpusetFilter struct {
+}
+
+// Name returns the name of the filter
+func (f *CpusetFilter) Name() string {
+	return "cpuset"
+}
+
+// Filter is exported
+func (f *CpusetFilter) Filter(config *cluster.ContainerConfig, nodes []*node.Node, _ bool) ([]*node.Node, error) {
+	requested, err := cluster.ParseCpuset(config.HostConfig.CpusetCpus)
+	if err != nil {
+		return nil, err
+	}
+	if len(requested) == 0 {
+		return nodes, nil
+	}
+
+	candidates := []*node.Node{}
+	for _, node := range nodes {
+		if !f.cpusetAlreadyPinned(node, requested) {
+			candidates = append(candidates, node)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("unable to find a node with cpuset %s available", config.HostConfig.CpusetCpus)
+	}
+	return candidates, nil
+}
+
+func (f *CpusetFilter) cpusetAlreadyPinned(node *node.Node, requested map[int]bool) bool {
+	for _, c := range node.Containers {
+		if c.Config == nil {
+			continue
+		}
+		pinned, err := cluster.ParseCpuset(c.Config.HostConfig.CpusetCpus)
+		if err != nil {
+			continue
+		}
+		for cpu := range pinned {
+			if requested[cpu] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetFilters returns a list of the cpusets requested by the container
+func (f *CpusetFilter) GetFilters(config *cluster.ContainerConfig) ([]string, error) {
+	if config.HostConfig.CpusetCpus == "" {
+		return nil, nil
+	}
+	return []string{fmt.Sprintf("cpuset %s available", config.HostConfig.CpusetCpus)}, nil
+}
@@ -0,0 +1,64 @@
+package filter
+
+import (
+	"testing"
+
+	containertypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func pinnedContainer(cpuset string) *cluster.Container {
+	return &cluster.Container{
+		Config: &cluster.ContainerConfig{
+			HostConfig: containertypes.HostConfig{
+				Resources: containertypes.Resources{CpusetCpus: cpuset},
+			},
+		},
+	}
+}
+
+func TestCpusetFilter(t *testing.T) {
+	var (
+		f     = CpusetFilter{}
+		nodes = []*node.Node{
+			{
+				ID:         "node-0-id",
+				Name:       "node-0-name",
+				Containers: []*cluster.Container{pinnedContainer("0-1")},
+			},
+			{
+				ID:         "node-1-id",
+				Name:       "node-1-name",
+				Containers: []*cluster.Container{pinnedContainer("2,3")},
+			},
+		}
+		result []*node.Node
+		err    error
+	)
+
+	// No cpuset requested, all the nodes are candidates.
+	result, err = f.Filter(&cluster.ContainerConfig{}, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, nodes)
+
+	// CPU 1 is pinned on node-0.
+	config := &cluster.ContainerConfig{HostConfig: containertypes.HostConfig{
+		Resources: containertypes.Resources{CpusetCpus: "1"},
+	}}
+	result, err = f.Filter(config, nodes, true)
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, result[0], nodes[1])
+
+	// CPU 1 is pinned on node-0 and CPU 2 on node-1.
+	config.HostConfig.CpusetCpus = "1-2"
+	_, err = f.Filter(config, nodes, true)
+	assert.Error(t, err)
+
+	// Invalid cpuset.
+	config.HostConfig.CpusetCpus = "3-1"
+	_, err = f.Filter(config, nodes, true)
+	assert.Error(t, err)
+}
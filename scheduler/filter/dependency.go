@@ -40,24 +40,46 @@ func (f *DependencyFilter) Filter(config *cluster.ContainerConfig, nodes []*node
 		net = append(net, strings.TrimPrefix(string(config.HostConfig.NetworkMode), "container:"))
 	}
 
+	// Named volumes existing on some nodes only, so that an empty volume
+	// doesn't get silently created on another node. Volumes that don't exist
+	// yet or whose driver is multi-host are not dependencies.
+	localVolumes := []string{}
+	for _, name := range config.NamedVolumes() {
+		for _, node := range nodes {
+			if hasLocalVolume(node, name) {
+				localVolumes = append(localVolumes, name)
+				break
+			}
+		}
+	}
+
 	candidates := []*node.Node{}
 	for _, node := range nodes {
 		if f.check(volumes, node) &&
 			f.check(links, node) &&
-			f.check(net, node) {
+			f.check(net, node) &&
+			f.checkVolumes(localVolumes, node) {
 			candidates = append(candidates, node)
 		}
 	}
 
 	if len(candidates) == 0 {
-		return nil, fmt.Errorf("Unable to find a node fulfilling all dependencies: %s", f.String(config))
+		return nil, fmt.Errorf("Unable to find a node fulfilling all dependencies: %s", strings.Join(dependencies(config, localVolumes), " "))
 	}
 
 	return candidates, nil
 }
 
 // GetFilters returns a list of the dependencies found in the container config.
+// The local volumes are left out, as they only are dependencies when they
+// exist on some of the nodes.
 func (f *DependencyFilter) GetFilters(config *cluster.ContainerConfig) ([]string, error) {
+	return dependencies(config, nil), nil
+}
+
+// dependencies returns the dependencies found in the container config, along
+// with the given local volumes.
+func dependencies(config *cluster.ContainerConfig, localVolumes []string) []string {
 	dependencies := []string{}
 	for _, volume := range config.HostConfig.VolumesFrom {
 		dependencies = append(dependencies, fmt.Sprintf("--volumes-from=%s", volume))
@@ -68,7 +90,10 @@ func (f *DependencyFilter) GetFilters(config *cluster.ContainerConfig) ([]string
 	if strings.HasPrefix(string(config.HostConfig.NetworkMode), "container:") {
 		dependencies = append(dependencies, fmt.Sprintf("--net=%s", config.HostConfig.NetworkMode))
 	}
-	return dependencies, nil
+	for _, name := range localVolumes {
+		dependencies = append(dependencies, fmt.Sprintf("--volume=%s", name))
+	}
+	return dependencies
 }
 
 // String gets a string representation of the dependencies found in the container config.
@@ -86,3 +111,22 @@ func (f *DependencyFilter) check(dependencies []string, node *node.Node) bool {
 	}
 	return true
 }
+
+// checkVolumes ensures that the node owns all the local volumes.
+func (f *DependencyFilter) checkVolumes(volumes []string, node *node.Node) bool {
+	for _, name := range volumes {
+		if !hasLocalVolume(node, name) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasLocalVolume(node *node.Node, name string) bool {
+	for _, volume := range node.Volumes {
+		if volume.Name == name && volume.IsLocal() {
+			return true
+		}
+	}
+	return false
+}
@@ -293,3 +293,58 @@ func TestDependencyFilterChaining(t *testing.T) {
 	_, err = f.Filter(config, nodes, true)
 	assert.Error(t, err)
 }
+
+func TestDependencyFilterVolumes(t *testing.T) {
+	var (
+		f     = DependencyFilter{}
+		nodes = []*node.Node{
+			{
+				ID:   "node-0-id",
+				Name: "node-0-name",
+				Volumes: cluster.Volumes{
+					{Volume: types.Volume{Name: "shared", Driver: "local"}},
+					{Volume: types.Volume{Name: "data-0", Driver: "local"}},
+					{Volume: types.Volume{Name: "remote", Driver: "rexray", Scope: "global"}},
+				},
+			},
+			{
+				ID:   "node-1-id",
+				Name: "node-1-name",
+				Volumes: cluster.Volumes{
+					{Volume: types.Volume{Name: "shared", Driver: "local"}},
+					{Volume: types.Volume{Name: "data-1", Driver: "local"}},
+					{Volume: types.Volume{Name: "remote", Driver: "rexray", Scope: "global"}},
+				},
+			},
+			{
+				ID:   "node-2-id",
+				Name: "node-2-name",
+			},
+		}
+		result []*node.Node
+		err    error
+	)
+
+	newConfig := func(binds ...string) *cluster.ContainerConfig {
+		return &cluster.ContainerConfig{HostConfig: containertypes.HostConfig{Binds: binds}}
+	}
+
+	// Host paths, new and multi-host volumes are not dependencies.
+	result, err = f.Filter(newConfig("/host:/data", "new:/new", "remote:/remote"), nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, nodes)
+
+	// A local volume ties the container to its node.
+	result, err = f.Filter(newConfig("data-1:/data:ro", "remote:/remote"), nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[1]})
+
+	// A local volume existing on several nodes.
+	result, err = f.Filter(newConfig("shared:/shared"), nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[0], nodes[1]})
+
+	// No node owns both volumes.
+	_, err = f.Filter(newConfig("data-0:/data0", "data-1:/data1", "new:/new"), nodes, true)
+	assert.EqualError(t, err, "Unable to find a node fulfilling all dependencies: --volume=data-0 --volume=data-1")
+}
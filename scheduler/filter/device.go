@@ -0,0 +1,116 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+)
+
+const (
+	// devicesLabel lists the devices available on a node.
+	devicesLabel = "swarm.devices"
+	// exclusiveDevicesLabel lists the devices of a node that only one
+	// container can use at a time.
+	exclusiveDevicesLabel = "swarm.devices.exclusive"
+)
+
+// DeviceFilter only keeps the nodes advertising the devices requested by a
+// container, and skips the nodes where a requested exclusive device is
+// already used by another container. Nodes which don't advertise devices are
+// refused to the containers requesting some, so the filter isn't enabled by
+// default.
+type DeviceFilter struct {
+}
+
+// Name returns the name of the filter
+func (f *DeviceFilter) Name() string {
+	return "device"
+}
+
+// Filter is exported
+func (f *DeviceFilter) Filter(config *cluster.ContainerConfig, nodes []*node.Node, _ bool) ([]*node.Node, error) {
+	requested := requestedDevices(config)
+	if len(requested) == 0 {
+		return nodes, nil
+	}
+
+	candidates := []*node.Node{}
+	for _, node := range nodes {
+		if f.devicesAvailable(node, requested) {
+			candidates = append(candidates, node)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("unable to find a node with devices %s available", strings.Join(requested, ", "))
+	}
+	return candidates, nil
+}
+
+func (f *DeviceFilter) devicesAvailable(node *node.Node, requested []string) bool {
+	var (
+		shared    = labelSet(node.Labels[devicesLabel])
+		exclusive = labelSet(node.Labels[exclusiveDevicesLabel])
+	)
+	for _, device := range requested {
+		if exclusive[device] {
+			if deviceInUse(node, device) {
+				return false
+			}
+			continue
+		}
+		if !shared[device] {
+			return false
+		}
+	}
+	return true
+}
+
+// deviceInUse returns true if a running container of the node, or a
+// container being created on it, uses the device.
+func deviceInUse(node *node.Node, device string) bool {
+	for _, c := range node.Containers {
+		if c.Config == nil {
+			continue
+		}
+		// Pending containers don't have a state yet.
+		if c.Info.ContainerJSONBase != nil && c.Info.State != nil && !c.Info.State.Running {
+			continue
+		}
+		for _, d := range c.Config.HostConfig.Devices {
+			if d.PathOnHost == device {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func requestedDevices(config *cluster.ContainerConfig) []string {
+	devices := []string{}
+	for _, d := range config.HostConfig.Devices {
+		devices = append(devices, d.PathOnHost)
+	}
+	return devices
+}
+
+// labelSet parses a comma separated label value into a set.
+func labelSet(value string) map[string]bool {
+	set := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			set[item] = true
+		}
+	}
+	return set
+}
+
+// GetFilters returns the devices requested by the container
+func (f *DeviceFilter) GetFilters(config *cluster.ContainerConfig) ([]string, error) {
+	filters := []string{}
+	for _, device := range requestedDevices(config) {
+		filters = append(filters, fmt.Sprintf("device %s", device))
+	}
+	return filters, nil
+}
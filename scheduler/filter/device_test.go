@@ -0,0 +1,90 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func deviceConfig(devices ...string) *cluster.ContainerConfig {
+	config := &cluster.ContainerConfig{}
+	for _, device := range devices {
+		config.HostConfig.Devices = append(config.HostConfig.Devices, containertypes.DeviceMapping{PathOnHost: device, PathInContainer: device})
+	}
+	return config
+}
+
+func TestDeviceFilter(t *testing.T) {
+	var (
+		f     = DeviceFilter{}
+		nodes = []*node.Node{
+			{
+				ID:     "node-0-id",
+				Name:   "node-0-name",
+				Labels: map[string]string{"swarm.devices": "/dev/fuse"},
+			},
+			{
+				ID:   "node-1-id",
+				Name: "node-1-name",
+				Labels: map[string]string{
+					"swarm.devices":           "/dev/fuse, /dev/kvm",
+					"swarm.devices.exclusive": "/dev/ttyUSB0",
+				},
+			},
+			{
+				ID:     "node-2-id",
+				Name:   "node-2-name",
+				Labels: map[string]string{},
+			},
+		}
+		result []*node.Node
+		err    error
+	)
+
+	// No device requested.
+	result, err = f.Filter(&cluster.ContainerConfig{}, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, nodes)
+
+	result, err = f.Filter(deviceConfig("/dev/fuse"), nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[0], nodes[1]})
+
+	result, err = f.Filter(deviceConfig("/dev/fuse", "/dev/kvm"), nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[1]})
+
+	result, err = f.Filter(deviceConfig("/dev/ttyUSB0"), nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[1]})
+
+	// The exclusive device is taken once a running or pending container
+	// uses it.
+	nodes[1].Containers = cluster.Containers{{Config: deviceConfig("/dev/ttyUSB0")}}
+	_, err = f.Filter(deviceConfig("/dev/ttyUSB0"), nodes, true)
+	assert.Error(t, err)
+	nodes[1].Containers[0].Info = types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{State: &types.ContainerState{Running: true}}}
+	_, err = f.Filter(deviceConfig("/dev/ttyUSB0"), nodes, true)
+	assert.Error(t, err)
+
+	// The devices of stopped containers are available.
+	nodes[1].Containers[0].Info.State.Running = false
+	result, err = f.Filter(deviceConfig("/dev/ttyUSB0"), nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[1]})
+	nodes[1].Containers[0].Info.State.Running = true
+
+	// Shared devices can be used by several containers.
+	result, err = f.Filter(deviceConfig("/dev/kvm"), nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[1]})
+
+	// Nodes which don't advertise devices are refused, even when the other
+	// filters removed the nodes advertising them.
+	_, err = f.Filter(deviceConfig("/dev/fuse"), nodes[2:], true)
+	assert.Error(t, err)
+}
@@ -30,6 +30,11 @@ func init() {
 	filters = []Filter{
 		&HealthFilter{},
 		&PortFilter{},
+		&CpusetFilter{},
+		&RuntimeFilter{},
+		&NetworkFilter{},
+		&DeviceFilter{},
+		&PlatformFilter{},
 		&SlotsFilter{},
 		&DependencyFilter{},
 		&AffinityFilter{},
@@ -99,6 +104,24 @@ func listAllFilters(filters []Filter, config *cluster.ContainerConfig, lastFilte
 	return allFilters
 }
 
+// optInFilters are the filters only enabled when chosen explicitly.
+var optInFilters = map[string]bool{
+	"device": true,
+}
+
+// Defaults returns the names of the filters enabled by default.
+func Defaults() []string {
+	names := []string{}
+
+	for _, filter := range filters {
+		if !optInFilters[filter.Name()] {
+			names = append(names, filter.Name())
+		}
+	}
+
+	return names
+}
+
 // List returns the names of all the available filters.
 func List() []string {
 	names := []string{}
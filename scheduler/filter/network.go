@@ -0,0 +1,61 @@
+package filter
+
+import (
+	"fmt"
+
+	containertypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+)
+
+// NetworkFilter only keeps the nodes which can see all the networks a
+// container connects to: the nodes sharing a multi-host network, or the node
+// owning a local network.
+type NetworkFilter struct {
+}
+
+// Name returns the name of the filter
+func (f *NetworkFilter) Name() string {
+	return "network"
+}
+
+// Filter is exported
+func (f *NetworkFilter) Filter(config *cluster.ContainerConfig, nodes []*node.Node, _ bool) ([]*node.Node, error) {
+	for _, name := range requestedNetworks(config) {
+		candidates := []*node.Node{}
+		for _, node := range nodes {
+			if node.Networks.Get(name) != nil {
+				candidates = append(candidates, node)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("unable to find a node with network %s", name)
+		}
+		nodes = candidates
+	}
+	return nodes, nil
+}
+
+// requestedNetworks returns the user defined networks the container connects
+// to.
+func requestedNetworks(config *cluster.ContainerConfig) []string {
+	names := []string{}
+	if mode := config.HostConfig.NetworkMode; mode != "" && mode.IsUserDefined() {
+		names = append(names, string(mode))
+	}
+	for name := range config.NetworkingConfig.EndpointsConfig {
+		if name != string(config.HostConfig.NetworkMode) && containertypes.NetworkMode(name).IsUserDefined() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// GetFilters returns the networks the container connects to
+func (f *NetworkFilter) GetFilters(config *cluster.ContainerConfig) ([]string, error) {
+	filters := []string{}
+	for _, name := range requestedNetworks(config) {
+		filters = append(filters, fmt.Sprintf("network %s", name))
+	}
+	return filters, nil
+}
@@ -0,0 +1,80 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkFilter(t *testing.T) {
+	var (
+		f       = NetworkFilter{}
+		engines = []*cluster.Engine{
+			{ID: "node-0-id", Name: "node-0-name"},
+			{ID: "node-1-id", Name: "node-1-name"},
+			{ID: "node-2-id", Name: "node-2-name"},
+		}
+		network = func(e *cluster.Engine, id, name, scope string) *cluster.Network {
+			return &cluster.Network{
+				NetworkResource: types.NetworkResource{ID: id, Name: name, Scope: scope},
+				Engine:          e,
+			}
+		}
+		nodes = []*node.Node{
+			{
+				ID:   "node-0-id",
+				Name: "node-0-name",
+				Networks: cluster.Networks{
+					network(engines[0], "overlay-id", "overlay", "global"),
+					network(engines[0], "local0-id", "local0", "local"),
+				},
+			},
+			{
+				ID:   "node-1-id",
+				Name: "node-1-name",
+				Networks: cluster.Networks{
+					network(engines[1], "overlay-id", "overlay", "global"),
+				},
+			},
+			{
+				ID:   "node-2-id",
+				Name: "node-2-name",
+			},
+		}
+		result []*node.Node
+		err    error
+	)
+
+	// Predefined networks don't restrict the nodes.
+	config := &cluster.ContainerConfig{HostConfig: containertypes.HostConfig{NetworkMode: "bridge"}}
+	result, err = f.Filter(config, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, nodes)
+
+	// node-2 isn't connected to the cluster store.
+	config = &cluster.ContainerConfig{HostConfig: containertypes.HostConfig{NetworkMode: "overlay"}}
+	result, err = f.Filter(config, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[0], nodes[1]})
+
+	// Local networks are only visible on their engine.
+	config = &cluster.ContainerConfig{
+		HostConfig: containertypes.HostConfig{NetworkMode: "overlay"},
+		NetworkingConfig: networktypes.NetworkingConfig{
+			EndpointsConfig: map[string]*networktypes.EndpointSettings{"local0": {}},
+		},
+	}
+	result, err = f.Filter(config, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[0]})
+
+	// Unknown network.
+	config = &cluster.ContainerConfig{HostConfig: containertypes.HostConfig{NetworkMode: "unknown"}}
+	_, err = f.Filter(config, nodes, true)
+	assert.Error(t, err)
+}
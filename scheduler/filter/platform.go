@@ -0,0 +1,61 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+	"github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// PlatformFilter only keeps the nodes able to run one of the platforms the
+// image of a container is published for. The OS, architecture and variant
+// of a platform are matched together, and a node runs the older variants of
+// its architecture.
+type PlatformFilter struct {
+}
+
+// Name returns the name of the filter
+func (f *PlatformFilter) Name() string {
+	return "platform"
+}
+
+// Filter is exported
+func (f *PlatformFilter) Filter(config *cluster.ContainerConfig, nodes []*node.Node, _ bool) ([]*node.Node, error) {
+	if len(config.Platforms) == 0 {
+		return nodes, nil
+	}
+
+	candidates := []*node.Node{}
+	for _, node := range nodes {
+		for _, p := range config.Platforms {
+			if cluster.PlatformSupported(node.Labels, p) {
+				candidates = append(candidates, node)
+				break
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("unable to find a node able to run image %s, available platforms: %s", config.Image, strings.Join(platformStrings(config.Platforms), ", "))
+	}
+	return candidates, nil
+}
+
+// GetFilters returns the platforms of the image
+func (f *PlatformFilter) GetFilters(config *cluster.ContainerConfig) ([]string, error) {
+	filters := []string{}
+	for _, p := range platformStrings(config.Platforms) {
+		filters = append(filters, fmt.Sprintf("platform %s", p))
+	}
+	return filters, nil
+}
+
+func platformStrings(platforms []v1.Platform) []string {
+	strs := []string{}
+	for _, p := range platforms {
+		strs = append(strs, cluster.PlatformString(p))
+	}
+	return strs
+}
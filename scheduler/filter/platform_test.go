@@ -0,0 +1,65 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+	"github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlatformFilter(t *testing.T) {
+	var (
+		f     = PlatformFilter{}
+		nodes = []*node.Node{
+			{
+				ID:     "node-0-id",
+				Name:   "node-0-name",
+				Labels: map[string]string{"ostype": "linux", "architecture": "amd64"},
+			},
+			{
+				ID:     "node-1-id",
+				Name:   "node-1-name",
+				Labels: map[string]string{"ostype": "linux", "architecture": "arm", "variant": "v7"},
+			},
+			{
+				ID:     "node-2-id",
+				Name:   "node-2-name",
+				Labels: map[string]string{"ostype": "windows", "architecture": "arm64", "variant": "v8"},
+			},
+		}
+		result []*node.Node
+		err    error
+	)
+
+	// Without platforms, all the nodes are kept.
+	result, err = f.Filter(&cluster.ContainerConfig{}, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, nodes)
+
+	// The OS and architecture of a platform are matched together.
+	config := &cluster.ContainerConfig{Platforms: []v1.Platform{
+		{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		{OS: "windows", Architecture: "amd64"},
+	}}
+	_, err = f.Filter(config, nodes, true)
+	assert.Error(t, err)
+
+	// A node runs the older variants of its architecture.
+	config = &cluster.ContainerConfig{Platforms: []v1.Platform{
+		{OS: "linux", Architecture: "arm", Variant: "v6"},
+	}}
+	result, err = f.Filter(config, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[1]})
+
+	// But not the newer ones.
+	config = &cluster.ContainerConfig{Platforms: []v1.Platform{
+		{OS: "linux", Architecture: "arm", Variant: "v8"},
+		{OS: "linux", Architecture: "amd64"},
+	}}
+	result, err = f.Filter(config, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[0]})
+}
@@ -0,0 +1,177 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+)
+
+const defaultRuntime = "runc"
+
+// RuntimeFilter only keeps the nodes supporting the runtime, the security
+// profiles and the volume and network driver plugins requested by a
+// container.
+type RuntimeFilter struct {
+}
+
+// Name returns the name of the filter
+func (f *RuntimeFilter) Name() string {
+	return "runtime"
+}
+
+// Filter is exported
+func (f *RuntimeFilter) Filter(config *cluster.ContainerConfig, nodes []*node.Node, _ bool) ([]*node.Node, error) {
+	var (
+		runtime        = config.HostConfig.Runtime
+		securityOpts   = requestedSecurityOpts(config)
+		volumeDrivers  = requestedVolumeDrivers(config)
+		networkDrivers = requestedNetworkDrivers(config, nodes)
+	)
+
+	candidates := []*node.Node{}
+	for _, node := range nodes {
+		if runtime != "" && !supportsRuntime(node, runtime) {
+			continue
+		}
+		if !supportsSecurityOpts(node, securityOpts) {
+			continue
+		}
+		if !supportsPlugins(node.Plugins.Volume, volumeDrivers) || !supportsPlugins(node.Plugins.Network, networkDrivers) {
+			continue
+		}
+		candidates = append(candidates, node)
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("unable to find a node supporting the requested runtime, security options and plugins")
+	}
+	return candidates, nil
+}
+
+func supportsRuntime(node *node.Node, runtime string) bool {
+	// Engines that don't report their runtimes only support the default one.
+	if len(node.Runtimes) == 0 {
+		return runtime == defaultRuntime
+	}
+	for _, r := range node.Runtimes {
+		if r == runtime {
+			return true
+		}
+	}
+	return false
+}
+
+// requestedSecurityOpts returns the security features (seccomp, apparmor)
+// the container needs a profile for.
+func requestedSecurityOpts(config *cluster.ContainerConfig) []string {
+	requested := []string{}
+	for _, opt := range config.HostConfig.SecurityOpt {
+		// Both key=value and the legacy key:value forms are accepted.
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			kv = strings.SplitN(opt, ":", 2)
+		}
+		if len(kv) != 2 || kv[1] == "unconfined" {
+			continue
+		}
+		if kv[0] == "seccomp" || kv[0] == "apparmor" {
+			requested = append(requested, kv[0])
+		}
+	}
+	return requested
+}
+
+func supportsSecurityOpts(node *node.Node, requested []string) bool {
+	if len(requested) == 0 {
+		return true
+	}
+	opts, err := types.DecodeSecurityOptions(node.SecurityOptions)
+	if err != nil {
+		return false
+	}
+	for _, name := range requested {
+		found := false
+		for _, opt := range opts {
+			if opt.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// requestedVolumeDrivers returns the volume drivers used by the container.
+func requestedVolumeDrivers(config *cluster.ContainerConfig) []string {
+	drivers := []string{}
+	if driver := config.HostConfig.VolumeDriver; driver != "" && driver != "local" {
+		drivers = append(drivers, driver)
+	}
+	for _, m := range config.HostConfig.Mounts {
+		if m.VolumeOptions != nil && m.VolumeOptions.DriverConfig != nil {
+			if driver := m.VolumeOptions.DriverConfig.Name; driver != "" && driver != "local" {
+				drivers = append(drivers, driver)
+			}
+		}
+	}
+	return drivers
+}
+
+// requestedNetworkDrivers returns the drivers of the networks the container
+// connects to, as known by any of the nodes.
+func requestedNetworkDrivers(config *cluster.ContainerConfig, nodes []*node.Node) []string {
+	drivers := []string{}
+	for _, name := range requestedNetworks(config) {
+		for _, node := range nodes {
+			if network := node.Networks.Get(name); network != nil {
+				drivers = append(drivers, network.Driver)
+				break
+			}
+		}
+	}
+	return drivers
+}
+
+// supportsPlugins returns true if all the requested drivers are in the list
+// of plugins reported by the node. Nodes that don't report any plugin are not
+// checked.
+func supportsPlugins(plugins, requested []string) bool {
+	if len(plugins) == 0 {
+		return true
+	}
+	for _, driver := range requested {
+		found := false
+		for _, plugin := range plugins {
+			if plugin == driver || plugin == driver+":latest" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// GetFilters returns the runtime, security options and plugins requested by
+// the container
+func (f *RuntimeFilter) GetFilters(config *cluster.ContainerConfig) ([]string, error) {
+	filters := []string{}
+	if config.HostConfig.Runtime != "" {
+		filters = append(filters, fmt.Sprintf("runtime %s", config.HostConfig.Runtime))
+	}
+	for _, opt := range requestedSecurityOpts(config) {
+		filters = append(filters, fmt.Sprintf("security option %s", opt))
+	}
+	for _, driver := range requestedVolumeDrivers(config) {
+		filters = append(filters, fmt.Sprintf("volume plugin %s", driver))
+	}
+	return filters, nil
+}
@@ -0,0 +1,97 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeFilter(t *testing.T) {
+	var (
+		f     = RuntimeFilter{}
+		nodes = []*node.Node{
+			{
+				ID:              "node-0-id",
+				Name:            "node-0-name",
+				Runtimes:        []string{"runc"},
+				SecurityOptions: []string{"name=seccomp,profile=default"},
+				Plugins: types.PluginsInfo{
+					Volume:  []string{"local"},
+					Network: []string{"bridge", "host", "null", "overlay"},
+				},
+			},
+			{
+				ID:              "node-1-id",
+				Name:            "node-1-name",
+				Runtimes:        []string{"runc", "runsc"},
+				SecurityOptions: []string{"apparmor", "seccomp"},
+				Plugins: types.PluginsInfo{
+					Volume:  []string{"local", "vieux/sshfs:latest"},
+					Network: []string{"bridge", "host", "null", "weave"},
+				},
+				Networks: cluster.Networks{
+					{NetworkResource: types.NetworkResource{ID: "weave-id", Name: "weavenet", Driver: "weave"}},
+				},
+			},
+			{
+				ID:   "node-2-id",
+				Name: "node-2-name",
+			},
+		}
+		result []*node.Node
+		err    error
+	)
+
+	// Nothing requested, all the nodes are candidates.
+	result, err = f.Filter(&cluster.ContainerConfig{}, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, nodes)
+
+	// Default runtime.
+	config := &cluster.ContainerConfig{HostConfig: containertypes.HostConfig{Runtime: "runc"}}
+	result, err = f.Filter(config, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, nodes)
+
+	// Only node-1 has the runsc runtime.
+	config = &cluster.ContainerConfig{HostConfig: containertypes.HostConfig{Runtime: "runsc"}}
+	result, err = f.Filter(config, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[1]})
+
+	// Only node-1 supports apparmor, unconfined profiles are ignored.
+	config = &cluster.ContainerConfig{HostConfig: containertypes.HostConfig{SecurityOpt: []string{"apparmor=docker-default", "seccomp=unconfined"}}}
+	result, err = f.Filter(config, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[1]})
+
+	// node-0 and node-1 support seccomp.
+	config = &cluster.ContainerConfig{HostConfig: containertypes.HostConfig{SecurityOpt: []string{"seccomp:profile.json"}}}
+	result, err = f.Filter(config, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[0], nodes[1]})
+
+	// node-0 doesn't have the volume plugin, node-2 doesn't report its plugins.
+	config = &cluster.ContainerConfig{HostConfig: containertypes.HostConfig{Mounts: []mount.Mount{
+		{Type: mount.TypeVolume, Source: "data", Target: "/data", VolumeOptions: &mount.VolumeOptions{DriverConfig: &mount.Driver{Name: "vieux/sshfs"}}},
+	}}}
+	result, err = f.Filter(config, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[1], nodes[2]})
+
+	// node-0 doesn't have the weave network plugin.
+	config = &cluster.ContainerConfig{HostConfig: containertypes.HostConfig{NetworkMode: "weavenet"}}
+	result, err = f.Filter(config, nodes, true)
+	assert.NoError(t, err)
+	assert.Equal(t, result, []*node.Node{nodes[1], nodes[2]})
+
+	// No node has the kata runtime.
+	config = &cluster.ContainerConfig{HostConfig: containertypes.HostConfig{Runtime: "kata"}}
+	_, err = f.Filter(config, nodes, true)
+	assert.Error(t, err)
+}
@@ -2,7 +2,9 @@ package node
 
 import (
 	"errors"
+	"fmt"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/swarm/cluster"
 )
 
@@ -21,6 +23,15 @@ type Node struct {
 	TotalMemory int64
 	TotalCpus   int64
 
+	UsedResources  map[string]int64
+	TotalResources map[string]int64
+
+	Runtimes        []string
+	SecurityOptions []string
+	Plugins         types.PluginsInfo
+	Networks        cluster.Networks
+	Volumes         cluster.Volumes
+
 	HealthIndicator int64
 }
 
@@ -38,6 +49,13 @@ func NewNode(e *cluster.Engine) *Node {
 		UsedCpus:        e.UsedCpus(),
 		TotalMemory:     e.TotalMemory(),
 		TotalCpus:       e.TotalCpus(),
+		UsedResources:   e.UsedResources(),
+		TotalResources:  e.TotalResources(),
+		Runtimes:        e.Runtimes,
+		SecurityOptions: e.SecurityOptions,
+		Plugins:         e.Plugins,
+		Networks:        e.Networks(),
+		Volumes:         e.Volumes(),
 		HealthIndicator: e.HealthIndicator(),
 	}
 }
@@ -55,13 +73,28 @@ func (n *Node) Container(IDOrName string) *cluster.Container {
 // AddContainer injects a container into the internal state.
 func (n *Node) AddContainer(container *cluster.Container) error {
 	if container.Config != nil {
-		memory := container.Config.HostConfig.Memory
-		cpus := container.Config.HostConfig.CPUShares
+		memory := container.Config.ReservedMemory()
+		cpus := container.Config.ReservedCpus()
 		if n.TotalMemory-memory < 0 || n.TotalCpus-cpus < 0 {
 			return errors.New("not enough resources")
 		}
+		resources, err := container.Config.GenericResources()
+		if err != nil {
+			return err
+		}
+		for name, count := range resources {
+			if n.TotalResources[name]-n.UsedResources[name]-count < 0 {
+				return fmt.Errorf("not enough %s resources", name)
+			}
+		}
 		n.UsedMemory = n.UsedMemory + memory
 		n.UsedCpus = n.UsedCpus + cpus
+		if len(resources) > 0 && n.UsedResources == nil {
+			n.UsedResources = make(map[string]int64)
+		}
+		for name, count := range resources {
+			n.UsedResources[name] += count
+		}
 	}
 	n.Containers = append(n.Containers, container)
 	return nil
@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/swarm/cluster"
+	"github.com/docker/swarm/scheduler/node"
+	"github.com/docker/swarm/scheduler/strategy"
+)
+
+// Pool is a set of nodes sharing an engine label, with its own placement
+// strategy and default constraints.
+type Pool struct {
+	Name        string
+	Label       string
+	Value       string
+	Strategy    strategy.PlacementStrategy
+	Constraints []string
+}
+
+// ParsePool parses a pool definition, expressed as a comma separated list of
+// key=value pairs (ex. name=batch,label=pool=batch,strategy=binpack,constraint=storage==ssd).
+// The label defaults to pool=<name>.
+func ParsePool(definition string) (*Pool, error) {
+	pool := &Pool{}
+	for _, option := range strings.Split(definition, ",") {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("invalid pool option %q in %q", option, definition)
+		}
+		switch kv[0] {
+		case "name":
+			pool.Name = kv[1]
+		case "label":
+			label := strings.SplitN(kv[1], "=", 2)
+			if len(label) != 2 || label[0] == "" || label[1] == "" {
+				return nil, fmt.Errorf("invalid pool label %q, expected key=value", kv[1])
+			}
+			pool.Label, pool.Value = label[0], label[1]
+		case "strategy":
+			s, err := strategy.New(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid pool strategy %q: %v", kv[1], err)
+			}
+			pool.Strategy = s
+		case "constraint":
+			if constraintKey(kv[1]) == "" {
+				return nil, fmt.Errorf("invalid pool constraint %q", kv[1])
+			}
+			pool.Constraints = append(pool.Constraints, kv[1])
+		default:
+			return nil, fmt.Errorf("unsupported pool option %q", kv[0])
+		}
+	}
+
+	if pool.Name == "" {
+		return nil, fmt.Errorf("missing pool name in %q", definition)
+	}
+	if pool.Label == "" {
+		pool.Label, pool.Value = "pool", pool.Name
+	}
+	return pool, nil
+}
+
+// nodes returns the nodes belonging to the pool.
+func (p *Pool) nodes(nodes []*node.Node) []*node.Node {
+	members := []*node.Node{}
+	for _, n := range nodes {
+		if value, ok := n.Labels[p.Label]; ok && value == p.Value {
+			members = append(members, n)
+		}
+	}
+	return members
+}
+
+// withConstraints returns a copy of config with the default constraints of
+// the pool on the keys the container doesn't constrain itself.
+func (p *Pool) withConstraints(config *cluster.ContainerConfig) (*cluster.ContainerConfig, error) {
+	if len(p.Constraints) == 0 {
+		return config, nil
+	}
+
+	constrained := make(map[string]bool)
+	for _, constraint := range config.Constraints() {
+		constrained[constraintKey(constraint)] = true
+	}
+
+	cfg := *config
+	cfg.Labels = make(map[string]string, len(config.Labels))
+	for k, v := range config.Labels {
+		cfg.Labels[k] = v
+	}
+	for _, constraint := range p.Constraints {
+		if constrained[constraintKey(constraint)] {
+			continue
+		}
+		if err := cfg.AddConstraint(constraint); err != nil {
+			return nil, err
+		}
+	}
+	return &cfg, nil
+}
+
+// constraintKey returns the key of a constraint expression.
+func constraintKey(constraint string) string {
+	for i := 0; i < len(constraint)-1; i++ {
+		if (constraint[i] == '=' || constraint[i] == '!') && constraint[i+1] == '=' {
+			return strings.TrimSpace(constraint[:i])
+		}
+	}
+	return ""
+}
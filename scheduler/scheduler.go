@@ -2,8 +2,10 @@ package scheduler
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
+	"sort"
 	"strings"
-	"sync"
 
 	"github.com/docker/swarm/cluster"
 	"github.com/docker/swarm/scheduler/filter"
@@ -15,44 +17,161 @@ var (
 	errNoNodeAvailable = errors.New("No nodes available in the cluster")
 )
 
-// Scheduler is exported
+// Scheduler is exported. It holds no state of its own, so it is safe to use
+// from concurrent placements.
 type Scheduler struct {
-	sync.Mutex
-
 	strategy strategy.PlacementStrategy
 	filters  []filter.Filter
+	pools    map[string]*Pool
+
+	// strategies are the strategies containers can request, initialized
+	// once rather than on every placement.
+	strategies map[string]strategy.PlacementStrategy
+
+	extenders []*Extender
 }
 
 // New is exported
-func New(strategy strategy.PlacementStrategy, filters []filter.Filter) *Scheduler {
+func New(placement strategy.PlacementStrategy, filters []filter.Filter) *Scheduler {
+	strategies := make(map[string]strategy.PlacementStrategy)
+	for _, name := range strategy.List() {
+		if s, err := strategy.New(name); err == nil {
+			strategies[name] = s
+		}
+	}
 	return &Scheduler{
-		strategy: strategy,
-		filters:  filters,
+		strategy:   placement,
+		filters:    filters,
+		pools:      make(map[string]*Pool),
+		strategies: strategies,
+	}
+}
+
+// AddPool defines a node pool. It must be called before the scheduler is
+// used.
+func (s *Scheduler) AddPool(pool *Pool) error {
+	if _, exists := s.pools[pool.Name]; exists {
+		return fmt.Errorf("pool %s is already defined", pool.Name)
+	}
+	s.pools[pool.Name] = pool
+	return nil
+}
+
+// AddExtender appends an extender, called by CallExtenders after the
+// built-in filters. It must be called before the scheduler is used.
+func (s *Scheduler) AddExtender(extender *Extender) {
+	if extender.client == nil {
+		extender.client = &http.Client{Timeout: extender.Timeout}
 	}
+	s.extenders = append(s.extenders, extender)
 }
 
 // SelectNodesForContainer will return a list of nodes where the container can
-// be scheduled, sorted by order or preference.
+// be scheduled, sorted by order or preference. The extenders are not called.
 func (s *Scheduler) SelectNodesForContainer(nodes []*node.Node, config *cluster.ContainerConfig) ([]*node.Node, error) {
-	candidates, err := s.selectNodesForContainer(nodes, config, true)
+	return s.SelectNodesWithExtenders(nodes, config, nil)
+}
+
+// SelectNodesWithExtenders is like SelectNodesForContainer, but only keeps the
+// nodes accepted by the extenders in decision, sorted by their scores.
+// decision may be nil when there are no extenders.
+func (s *Scheduler) SelectNodesWithExtenders(nodes []*node.Node, config *cluster.ContainerConfig, decision *ExtenderDecision) ([]*node.Node, error) {
+	nodes, config, placement, err := s.prepare(nodes, config)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := s.selectNodesForContainer(nodes, config, placement, decision, true)
 
 	if err != nil {
-		candidates, err = s.selectNodesForContainer(nodes, config, false)
+		candidates, err = s.selectNodesForContainer(nodes, config, placement, decision, false)
 	}
 	return candidates, err
 }
 
-func (s *Scheduler) selectNodesForContainer(nodes []*node.Node, config *cluster.ContainerConfig, soft bool) ([]*node.Node, error) {
+// CallExtenders calls the extenders with the nodes accepted by the filters.
+// It is meant to be called once per container placement, and its decision
+// passed to SelectNodesWithExtenders on every attempt. It returns nil if
+// there are no extenders.
+func (s *Scheduler) CallExtenders(nodes []*node.Node, config *cluster.ContainerConfig) (*ExtenderDecision, error) {
+	if len(s.extenders) == 0 {
+		return nil, nil
+	}
+
+	nodes, config, _, err := s.prepare(nodes, config)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted, err := filter.ApplyFilters(s.filters, config, nodes, true)
+	if err != nil || len(accepted) == 0 {
+		accepted, err = filter.ApplyFilters(s.filters, config, nodes, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(accepted) == 0 {
+		return nil, errNoNodeAvailable
+	}
+
+	accepted, scores, err := applyExtenders(s.extenders, config, accepted)
+	if err != nil {
+		return nil, err
+	}
+	decision := &ExtenderDecision{nodes: make(map[string]bool, len(accepted)), scores: scores}
+	for _, n := range accepted {
+		decision.nodes[n.ID] = true
+	}
+	return decision, nil
+}
+
+// prepare returns the nodes, the config and the strategy to place a
+// container with. Containers requesting a pool are only placed on its nodes,
+// using its strategy and default constraints.
+func (s *Scheduler) prepare(nodes []*node.Node, config *cluster.ContainerConfig) ([]*node.Node, *cluster.ContainerConfig, strategy.PlacementStrategy, error) {
+	placement := s.strategy
+
+	if name := config.Pool(); name != "" {
+		pool, ok := s.pools[name]
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("unknown pool: %s", name)
+		}
+		nodes = pool.nodes(nodes)
+		if pool.Strategy != nil {
+			placement = pool.Strategy
+		}
+		var err error
+		if config, err = pool.withConstraints(config); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	if name := config.Strategy(); name != "" {
+		var ok bool
+		if placement, ok = s.strategies[name]; !ok {
+			return nil, nil, nil, fmt.Errorf("invalid strategy %s: %v", name, strategy.ErrNotSupported)
+		}
+	}
+	return nodes, config, placement, nil
+}
+
+func (s *Scheduler) selectNodesForContainer(nodes []*node.Node, config *cluster.ContainerConfig, placement strategy.PlacementStrategy, decision *ExtenderDecision, soft bool) ([]*node.Node, error) {
 	accepted, err := filter.ApplyFilters(s.filters, config, nodes, soft)
 	if err != nil {
 		return nil, err
 	}
 
+	accepted = decision.filter(accepted)
 	if len(accepted) == 0 {
 		return nil, errNoNodeAvailable
 	}
 
-	return s.strategy.RankAndSort(config, accepted)
+	ranked, err := placement.RankAndSort(config, accepted)
+	if err != nil {
+		return nil, err
+	}
+	decision.sort(ranked)
+	return ranked, nil
 }
 
 // Strategy returns the strategy name
@@ -60,6 +179,23 @@ func (s *Scheduler) Strategy() string {
 	return s.strategy.Name()
 }
 
+// Pools returns the description of the node pools, sorted by name
+func (s *Scheduler) Pools() []string {
+	pools := []string{}
+	for _, pool := range s.pools {
+		description := fmt.Sprintf("%s: %s=%s", pool.Name, pool.Label, pool.Value)
+		if pool.Strategy != nil {
+			description += ", strategy " + pool.Strategy.Name()
+		}
+		if len(pool.Constraints) > 0 {
+			description += ", constraints " + strings.Join(pool.Constraints, " ")
+		}
+		pools = append(pools, description)
+	}
+	sort.Strings(pools)
+	return pools
+}
+
 // Filters returns the list of filter's name
 func (s *Scheduler) Filters() string {
 	filters := []string{}
@@ -116,3 +116,119 @@ func TestSelectNodesForContainerHybrid(t *testing.T) {
 	assert.Equal(t, 1, len(candidates))
 	assert.Equal(t, "node-0-id", candidates[0].ID)
 }
+
+func TestSelectNodesForContainerStrategyAndPools(t *testing.T) {
+	var (
+		s = New(&strategy.SpreadPlacementStrategy{}, []filter.Filter{&filter.ConstraintFilter{}})
+
+		nodes = []*node.Node{
+			{
+				ID:          "node-0-id",
+				Name:        "node-0-name",
+				Addr:        "node-0",
+				TotalMemory: 2 * 1024 * 1024 * 1024,
+				UsedMemory:  1 * 1024 * 1024 * 1024,
+				TotalCpus:   2,
+				Labels: map[string]string{
+					"pool":    "batch",
+					"storage": "ssd",
+				},
+			},
+
+			{
+				ID:          "node-1-id",
+				Name:        "node-1-name",
+				Addr:        "node-1",
+				TotalMemory: 2 * 1024 * 1024 * 1024,
+				TotalCpus:   2,
+				Labels: map[string]string{
+					"pool":    "batch",
+					"storage": "hdd",
+				},
+			},
+
+			{
+				ID:          "node-2-id",
+				Name:        "node-2-name",
+				Addr:        "node-2",
+				TotalMemory: 2 * 1024 * 1024 * 1024,
+				TotalCpus:   2,
+				Labels:      map[string]string{},
+			},
+		}
+
+		newConfig = func(labels map[string]string) *cluster.ContainerConfig {
+			return cluster.BuildContainerConfig(containertypes.Config{Labels: labels}, containertypes.HostConfig{
+				Resources: containertypes.Resources{
+					Memory: 256 * 1024 * 1024,
+				},
+			}, networktypes.NetworkingConfig{})
+		}
+	)
+
+	pool, err := ParsePool("name=batch,strategy=spread,constraint=storage==ssd")
+	assert.NoError(t, err)
+	assert.NoError(t, s.AddPool(pool))
+	assert.Error(t, s.AddPool(pool))
+
+	// The global strategy spreads containers on the least loaded nodes.
+	candidates, err := s.SelectNodesForContainer(nodes, newConfig(nil))
+	assert.NoError(t, err)
+	assert.NotEqual(t, "node-0-id", candidates[0].ID)
+
+	// The strategy label overrides it.
+	candidates, err = s.SelectNodesForContainer(nodes, newConfig(map[string]string{"com.docker.swarm.strategy": "binpack"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "node-0-id", candidates[0].ID)
+
+	_, err = s.SelectNodesForContainer(nodes, newConfig(map[string]string{"com.docker.swarm.strategy": "unknown"}))
+	assert.Error(t, err)
+
+	// Pools restrict placement to their nodes and apply their default
+	// constraints.
+	config := newConfig(map[string]string{"com.docker.swarm.pool": "batch"})
+	candidates, err = s.SelectNodesForContainer(nodes, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(candidates))
+	assert.Equal(t, "node-0-id", candidates[0].ID)
+	assert.Empty(t, config.Constraints())
+
+	// Containers can override the default constraints of their pool.
+	config = newConfig(map[string]string{"com.docker.swarm.pool": "batch"})
+	config.AddConstraint("storage==hdd")
+	candidates, err = s.SelectNodesForContainer(nodes, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(candidates))
+	assert.Equal(t, "node-1-id", candidates[0].ID)
+
+	_, err = s.SelectNodesForContainer(nodes, newConfig(map[string]string{"com.docker.swarm.pool": "unknown"}))
+	assert.Error(t, err)
+}
+
+func TestParsePool(t *testing.T) {
+	pool, err := ParsePool("name=batch")
+	assert.NoError(t, err)
+	assert.Equal(t, "pool", pool.Label)
+	assert.Equal(t, "batch", pool.Value)
+	assert.Nil(t, pool.Strategy)
+
+	pool, err = ParsePool("name=web,label=tier=frontend,strategy=spread,constraint=region==us-east,constraint=storage!=hdd")
+	assert.NoError(t, err)
+	assert.Equal(t, "web", pool.Name)
+	assert.Equal(t, "tier", pool.Label)
+	assert.Equal(t, "frontend", pool.Value)
+	assert.Equal(t, "spread", pool.Strategy.Name())
+	assert.Equal(t, []string{"region==us-east", "storage!=hdd"}, pool.Constraints)
+
+	for _, definition := range []string{
+		"",
+		"label=pool=batch",
+		"name=batch,label=pool",
+		"name=batch,strategy=unknown",
+		"name=batch,constraint=storage",
+		"name=batch,size=3",
+	} {
+		_, err := ParsePool(definition)
+		assert.Error(t, err, definition)
+	}
+}
@@ -295,3 +295,25 @@ func TestComplexPlacement(t *testing.T) {
 	// check that it ends up on the same node as the 3G
 	assert.Equal(t, node2.ID, node3.ID)
 }
+
+func TestPlaceContainerGenericResources(t *testing.T) {
+	s := &BinpackPlacementStrategy{}
+
+	nodes := []*node.Node{createNode("node-0", 4, 4), createNode("node-1", 4, 4)}
+	nodes[1].TotalResources = map[string]int64{"fpga": 2}
+
+	config := createConfig(0, 0)
+	config.Labels["com.docker.swarm.resources"] = "fpga:1"
+
+	// add two containers requesting one FPGA
+	for i := 0; i < 2; i++ {
+		node := selectTopNode(t, s, config, nodes)
+		assert.Equal(t, node.ID, "node-1")
+		assert.NoError(t, node.AddContainer(createContainer(fmt.Sprintf("c%d", i), config)))
+	}
+
+	// try to add another container requesting one FPGA
+	_, err := s.RankAndSort(config, nodes)
+	assert.Error(t, err)
+	assert.Error(t, nodes[1].AddContainer(createContainer("c2", config)))
+}
@@ -38,13 +38,24 @@ func (n weightedNodeList) Less(i, j int) bool {
 
 func weighNodes(config *cluster.ContainerConfig, nodes []*node.Node, healthinessFactor int64) (weightedNodeList, error) {
 	weightedNodes := weightedNodeList{}
+	cpus := config.ReservedCpus()
+	memory := config.ReservedMemory()
+	resources, err := config.GenericResources()
+	if err != nil {
+		return nil, err
+	}
 
 	for _, node := range nodes {
 		nodeMemory := node.TotalMemory
 		nodeCpus := node.TotalCpus
 
 		// Skip nodes that are smaller than the requested resources.
-		if nodeMemory < int64(config.HostConfig.Memory) || nodeCpus < config.HostConfig.CPUShares {
+		if nodeMemory < memory || nodeCpus < cpus {
+			continue
+		}
+
+		// Skip nodes without enough of the requested countable resources.
+		if !hasResources(node, resources) {
 			continue
 		}
 
@@ -53,11 +64,11 @@ func weighNodes(config *cluster.ContainerConfig, nodes []*node.Node, healthiness
 			memoryScore int64 = 100
 		)
 
-		if config.HostConfig.CPUShares > 0 {
-			cpuScore = (node.UsedCpus + config.HostConfig.CPUShares) * 100 / nodeCpus
+		if cpus > 0 {
+			cpuScore = (node.UsedCpus + cpus) * 100 / nodeCpus
 		}
-		if config.HostConfig.Memory > 0 {
-			memoryScore = (node.UsedMemory + config.HostConfig.Memory) * 100 / nodeMemory
+		if memory > 0 {
+			memoryScore = (node.UsedMemory + memory) * 100 / nodeMemory
 		}
 
 		if cpuScore <= 100 && memoryScore <= 100 {
@@ -71,3 +82,12 @@ func weighNodes(config *cluster.ContainerConfig, nodes []*node.Node, healthiness
 
 	return weightedNodes, nil
 }
+
+func hasResources(node *node.Node, resources map[string]int64) bool {
+	for name, count := range resources {
+		if node.TotalResources[name]-node.UsedResources[name] < count {
+			return false
+		}
+	}
+	return true
+}